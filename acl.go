@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//aclDefaultConsumer is the ACL entry used for consumers without a matching rule of their own
+const aclDefaultConsumer = "*"
+
+//aclRolesVersion is the version of ACL documents with roles, a plain document has no version
+const aclRolesVersion = 2
+
+//aclRule is a single allow or deny entry of a consumer.
+//A pattern is either "*" or "/package.Service/Method" where both segments may contain
+//path.Match wildcards, a wildcard never matches the "/" between the segments
+type aclRule struct {
+	pattern string
+	deny    bool
+	role    string //the role which the rule comes from, empty for own rules of a consumer
+}
+
+//isGlob returns true if the pattern contains wildcards
+func (r aclRule) isGlob() bool {
+	return strings.ContainsAny(r.pattern, "*?[\\")
+}
+
+//specificity is the number of literal characters in the pattern
+func (r aclRule) specificity() int {
+	literals := 0
+	for _, c := range r.pattern {
+		if !strings.ContainsRune("*?[]\\", c) {
+			literals++
+		}
+	}
+	return literals
+}
+
+//aclMatcher decides on methods for one consumer. It is built once when an ACL is loaded.
+//An exact method beats any wildcard, a wildcard with more literal characters beats
+//one with fewer and deny beats allow when both rules are equally specific
+type aclMatcher struct {
+	exact map[string][]aclRule //deny first
+	globs []aclRule            //most specific first
+}
+
+//compileAclMatcher builds a matcher from the rules of a consumer.
+//A method which is not "/package.Service/Method" or a broken pattern is loaded as before patterns were introduced:
+//it matches only a method named exactly the same, so that old ACL documents keep loading, and a warning is logged
+func compileAclMatcher(consumer string, rules []aclRule) *aclMatcher {
+	matcher := &aclMatcher{exact: make(map[string][]aclRule)}
+	for _, rule := range rules {
+		if rule.pattern == "*" {
+			rule.pattern = "/*/*"
+		}
+		_, err := path.Match(rule.pattern, "")
+		if !isValidAclMethod(rule.pattern) || err != nil {
+			log.Printf("acl: consumer '%s': '%s' is not a /package.Service/Method pattern, it matches only itself\n", consumer, rule.pattern)
+			matcher.exact[rule.pattern] = append(matcher.exact[rule.pattern], rule)
+			continue
+		}
+		if rule.isGlob() {
+			matcher.globs = append(matcher.globs, rule)
+			continue
+		}
+		matcher.exact[rule.pattern] = append(matcher.exact[rule.pattern], rule)
+	}
+	for _, rules := range matcher.exact {
+		sort.SliceStable(rules, func(i, j int) bool {
+			return rules[i].deny && !rules[j].deny
+		})
+	}
+	sort.SliceStable(matcher.globs, func(i, j int) bool {
+		a, b := matcher.globs[i], matcher.globs[j]
+		if a.specificity() != b.specificity() {
+			return a.specificity() > b.specificity()
+		}
+		return a.deny && !b.deny
+	})
+	return matcher
+}
+
+//decide returns the rule which decides on the method, found is false if no rule matches
+func (m *aclMatcher) decide(method string) (rule aclRule, found bool) {
+	if rules := m.exact[method]; len(rules) != 0 {
+		return rules[0], true
+	}
+	for _, rule = range m.globs {
+		if matched, _ := path.Match(rule.pattern, method); matched {
+			return rule, true
+		}
+	}
+	return aclRule{}, false
+}
+
+//matching returns all rules which match the method, the deciding rule goes first
+func (m *aclMatcher) matching(method string) []aclRule {
+	rules := append([]aclRule{}, m.exact[method]...)
+	for _, rule := range m.globs {
+		if matched, _ := path.Match(rule.pattern, method); matched {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+//aclEntry is the extended form of a consumer or a role entry in an ACL document
+type aclEntry struct {
+	Allow    []string `json:"allow"`
+	Deny     []string `json:"deny"`
+	Roles    []string `json:"roles"`    //roles of a consumer
+	Inherits []string `json:"inherits"` //parent roles of a role
+}
+
+//rules returns own allow and deny rules of the entry
+func (e aclEntry) rules() []aclRule {
+	rules := make([]aclRule, 0, len(e.Allow)+len(e.Deny))
+	for _, method := range e.Allow {
+		rules = append(rules, aclRule{pattern: method})
+	}
+	for _, method := range e.Deny {
+		rules = append(rules, aclRule{pattern: method, deny: true})
+	}
+	return rules
+}
+
+//aclDocument is an ACL document with roles
+type aclDocument struct {
+	Version   int                        `json:"version"`
+	Roles     map[string]json.RawMessage `json:"roles"`
+	Consumers map[string]json.RawMessage `json:"consumers"`
+}
+
+//aclPolicy is a validated ACL. A policy is never changed after parseAcl returns it,
+//so it can be shared between goroutines without locking
+type aclPolicy struct {
+	consumers map[string]*aclMatcher
+}
+
+//parseAcl unmarshals an ACL document and validates it.
+//A consumer maps either to a list of allowed methods:
+//	{"biz_user": ["/main.Biz/Check", "/main.Biz/Add"]}
+//or to allowed and denied methods:
+//	{"biz_admin": {"allow": ["/main.Biz/*"], "deny": ["/main.Biz/Test"]}}
+//A document with "version": 2 defines named roles,
+//a role may inherit other roles and a consumer may have several roles:
+//	{
+//		"version":   2,
+//		"roles":     {"reader": ["/main.Biz/Check"], "writer": {"allow": ["/main.Biz/Add"], "inherits": ["reader"]}},
+//		"consumers": {"biz_user": {"roles": ["writer"], "deny": ["/main.Biz/Check"]}}
+//	}
+//Rules of roles are merged into the rules of a consumer.
+//The "*" consumer holds defaults which apply when no rule of the consumer matches a method.
+//When several rules match a method the most specific one decides, see aclMatcher.
+//A method which is not "/package.Service/Method" loads with a warning and matches only itself, as it always did
+func parseAcl(data []byte) (*aclPolicy, error) {
+	entries := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	roles := make(map[string]json.RawMessage)
+	if isAclDocumentWithRoles(entries) {
+		doc := aclDocument{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("acl: %v", err)
+		}
+		if doc.Version != aclRolesVersion {
+			return nil, fmt.Errorf("acl: unsupported version %d", doc.Version)
+		}
+		entries, roles = doc.Consumers, doc.Roles
+	}
+	resolver := &aclRoleResolver{raw: roles, resolved: make(map[string][]aclRule), resolving: make(map[string]bool)}
+
+	consumers := make(map[string]*aclMatcher, len(entries))
+	for consumer, raw := range entries {
+		if consumer == "" {
+			return nil, fmt.Errorf("acl: empty consumer name")
+		}
+		entry, err := parseAclEntry(raw)
+		if err != nil && (consumer == "roles" || consumer == "consumers") {
+			return nil, fmt.Errorf("acl: consumer '%s': %v, a document with roles needs \"version\": %d", consumer, err, aclRolesVersion)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("acl: consumer '%s': %v", consumer, err)
+		}
+		if len(entry.Inherits) != 0 {
+			return nil, fmt.Errorf("acl: consumer '%s': only roles may inherit, use roles for a consumer", consumer)
+		}
+		rules := entry.rules()
+		for _, role := range entry.Roles {
+			roleRules, err := resolver.resolve(role)
+			if err != nil {
+				return nil, fmt.Errorf("acl: consumer '%s': %v", consumer, err)
+			}
+			rules = append(rules, roleRules...)
+		}
+		consumers[consumer] = compileAclMatcher(consumer, rules)
+	}
+	for role := range roles {
+		if _, err := resolver.resolve(role); err != nil {
+			return nil, fmt.Errorf("acl: %v", err)
+		}
+	}
+	return &aclPolicy{consumers: consumers}, nil
+}
+
+//isAclDocumentWithRoles returns true if the document has a numeric "version".
+//A consumer of the plain format maps to a list or an object, never to a number,
+//so a consumer named "version", "roles" or "consumers" can't be taken for a document with roles
+func isAclDocumentWithRoles(entries map[string]json.RawMessage) bool {
+	version, found := entries["version"]
+	if !found {
+		return false
+	}
+	var number float64
+	return json.Unmarshal(version, &number) == nil
+}
+
+//aclRoleResolver flattens roles with their parents into rules and detects inheritance cycles
+type aclRoleResolver struct {
+	raw       map[string]json.RawMessage
+	resolved  map[string][]aclRule
+	resolving map[string]bool
+}
+
+//resolve returns all rules of a role including rules of the roles it inherits
+func (r *aclRoleResolver) resolve(role string) ([]aclRule, error) {
+	if rules, found := r.resolved[role]; found {
+		return rules, nil
+	}
+	raw, found := r.raw[role]
+	if !found {
+		return nil, fmt.Errorf("unknown role '%s'", role)
+	}
+	if r.resolving[role] {
+		return nil, fmt.Errorf("role '%s' inherits itself", role)
+	}
+	r.resolving[role] = true
+	defer delete(r.resolving, role)
+
+	entry, err := parseAclEntry(raw)
+	if err != nil {
+		return nil, fmt.Errorf("role '%s': %v", role, err)
+	}
+	if len(entry.Roles) != 0 {
+		return nil, fmt.Errorf("role '%s': use inherits for parent roles", role)
+	}
+	rules := entry.rules()
+	for i := range rules {
+		rules[i].role = role
+	}
+	for _, parent := range entry.Inherits {
+		parentRules, err := r.resolve(parent)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parentRules...)
+	}
+	r.resolved[role] = rules
+	return rules, nil
+}
+
+//parseAclEntry accepts both a plain list of allowed methods and an aclEntry object
+func parseAclEntry(raw json.RawMessage) (aclEntry, error) {
+	var allow []string
+	if err := json.Unmarshal(raw, &allow); err == nil {
+		return aclEntry{Allow: allow}, nil
+	}
+	entry := aclEntry{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entry); err != nil {
+		return entry, fmt.Errorf("expected a list of methods or an object with allow and deny lists: %v", err)
+	}
+	return entry, nil
+}
+
+//isValidAclMethod returns true if method looks like "/package.Service/Method"
+func isValidAclMethod(method string) bool {
+	parts := strings.Split(method, "/")
+	return len(parts) == 3 && parts[0] == "" && parts[1] != "" && parts[2] != ""
+}
+
+//isAllowed returns true if a consumer and a method are allowed by the policy
+func (p *aclPolicy) isAllowed(consumer string, checkingMethod string) bool {
+	_, rule, found := p.decide(consumer, checkingMethod)
+	return found && !rule.deny
+}
+
+//decide returns the ACL entry and its rule which decide on a method for a consumer.
+//Rules of the consumer go first, the "*" entry is used if none of them matches
+func (p *aclPolicy) decide(consumer string, checkingMethod string) (entry string, rule aclRule, found bool) {
+	for _, entry = range []string{consumer, aclDefaultConsumer} {
+		matcher, known := p.consumers[entry]
+		if !known {
+			continue
+		}
+		if rule, found = matcher.decide(checkingMethod); found {
+			return entry, rule, true
+		}
+	}
+	return "", aclRule{}, false
+}
+
+//explain returns the decision on a method for a consumer with all matching rules,
+//the deciding rule goes first
+func (p *aclPolicy) explain(consumer string, checkingMethod string) *ExplainReply {
+	entry, decision, found := p.decide(consumer, checkingMethod)
+	reply := &ExplainReply{Allowed: found && !decision.deny}
+	switch {
+	case !found:
+		reply.Reason = fmt.Sprintf("no rule of '%s' or '%s' matches '%s'", consumer, aclDefaultConsumer, checkingMethod)
+	case decision.deny:
+		reply.Reason = fmt.Sprintf("denied by '%s' of '%s'", decision.pattern, entry)
+	default:
+		reply.Reason = fmt.Sprintf("allowed by '%s' of '%s'", decision.pattern, entry)
+	}
+	if !found {
+		return reply
+	}
+	for _, rule := range p.consumers[entry].matching(checkingMethod) {
+		reply.Rules = append(reply.Rules, &AclRule{Pattern: rule.pattern, Deny: rule.deny, Entry: entry, Role: rule.role})
+	}
+	return reply
+}
+
+//Explain is an implementation Explain function of AdminServer interface.
+//It reports how the active ACL decides on a method for a consumer
+func (m *MsCtx) Explain(ctx context.Context, req *ExplainRequest) (*ExplainReply, error) {
+	if req.Consumer == "" || !isValidAclMethod(req.Method) {
+		return nil, status.Error(codes.InvalidArgument, "expected a consumer and a method like /package.Service/Method")
+	}
+	return m.getAcl().explain(req.Consumer, req.Method), nil
+}
+
+//getAcl returns the active ACL policy
+func (m *MsCtx) getAcl() *aclPolicy {
+	return m.acl.Load().(*aclPolicy)
+}
+
+//statConsumer returns the key of a consumer in the statistics by consumer: the consumer itself
+//if the ACL has an entry of it and "*" otherwise, so that made up names can't grow the statistics
+func (m *MsCtx) statConsumer(consumer string) string {
+	if _, known := m.getAcl().consumers[consumer]; known {
+		return consumer
+	}
+	return aclDefaultConsumer
+}
+
+//ReloadAcl validates data and atomically replaces the active ACL with it.
+//If data is not a valid ACL the active ACL stays unchanged
+func (m *MsCtx) ReloadAcl(data string) error {
+	policy, err := parseAcl([]byte(data))
+	if err != nil {
+		return err
+	}
+	m.acl.Store(policy)
+	log.Println("acl reloaded")
+	return nil
+}
+
+//WatchAclFile checks the file every interval and reloads the ACL when the file changes.
+//It returns when ctx is done
+func (m *MsCtx) WatchAclFile(ctx context.Context, path string, interval time.Duration) {
+	var lastMod time.Time
+	var lastSize int64 = -1
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Println("can't stat acl file:", path, err)
+			continue
+		}
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Println("can't read acl file:", path, err)
+			continue
+		}
+		if err := m.ReloadAcl(string(data)); err != nil {
+			log.Println("acl file is rejected, keeping the previous acl:", path, err)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestACLValidation(t *testing.T) {
+	for idx, data := range []string{
+		`{.;`,
+		`{"": ["/main.Biz/Check"]}`,
+		`{"biz_user": "/main.Biz/Check"}`,
+	} {
+		if _, err := parseAcl([]byte(data)); err == nil {
+			t.Fatalf("[%d] expected error on bad acl %s", idx, data)
+		}
+	}
+	if _, err := parseAcl([]byte(ACLData)); err != nil {
+		t.Fatalf("unexpected error on valid acl: %v", err)
+	}
+
+	// methods which the old format accepted still load, they match only themselves
+	policy, err := parseAcl([]byte(`{"biz_user": ["main.Biz/Check", "/main.Biz", "/main.Biz/Check/More", "/main.Biz/[Check", "/main.Biz/Add"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error on an old acl: %v", err)
+	}
+	for idx, tc := range []struct {
+		method  string
+		allowed bool
+	}{
+		{"main.Biz/Check", true},
+		{"/main.Biz/[Check", true},
+		{"/main.Biz/Check", false},
+		{"/main.Biz/Add", true},
+	} {
+		if allowed := policy.isAllowed("biz_user", tc.method); allowed != tc.allowed {
+			t.Fatalf("[%d] %s: expected %v, got %v", idx, tc.method, tc.allowed, allowed)
+		}
+	}
+}
+
+func TestACLReload(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	msCtx, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+
+	if _, err := biz.Test(getConsumerCtx("biz_user"), &Nothing{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated before reload, got %v", err)
+	}
+
+	err = msCtx.ReloadAcl(`{"biz_user": ["/main.Biz/Test"]}`)
+	if err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if _, err := biz.Test(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("expected access after reload, got %v", err)
+	}
+
+	err = msCtx.ReloadAcl(`{"biz_user": "/main.Biz/Check"}`)
+	if err == nil {
+		t.Fatalf("expected error on bad acl, have nil")
+	}
+	if _, err := biz.Test(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("bad acl must keep the previous one, got %v", err)
+	}
+}
+
+func TestACLFileWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acl")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "acl.json")
+	if err := ioutil.WriteFile(path, []byte(ACLData), 0600); err != nil {
+		t.Fatalf("cant write acl file: %v", err)
+	}
+
+	ctx, finish := context.WithCancel(context.Background())
+	msCtx, err := StartMyMicroserviceWithConfig(ctx, listenAddr, "", Config{
+		AclFile:         path,
+		AclPollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	if msCtx.isConsumerAllowed("biz_user", "/main.Biz/Test") {
+		t.Fatalf("biz_user must not have access to Test before reload")
+	}
+
+	// the size changes too, so the change is noticed even with a coarse mtime
+	if err := ioutil.WriteFile(path, []byte(`{"biz_user": ["/main.Biz/Test", "/main.Biz/Check"]}`), 0600); err != nil {
+		t.Fatalf("cant write acl file: %v", err)
+	}
+	wait(5)
+	if !msCtx.isConsumerAllowed("biz_user", "/main.Biz/Test") {
+		t.Fatalf("acl file was not reloaded")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{.;`), 0600); err != nil {
+		t.Fatalf("cant write acl file: %v", err)
+	}
+	wait(5)
+	if !msCtx.isConsumerAllowed("biz_user", "/main.Biz/Test") {
+		t.Fatalf("bad acl file must keep the previous acl")
+	}
+}
+
+func TestACLDeny(t *testing.T) {
+	policy, err := parseAcl([]byte(`{
+	"biz_user":  ["/main.Biz/Check"],
+	"biz_admin": {"allow": ["/main.Biz/*"], "deny": ["/main.Biz/Test"]},
+	"tie":       {"allow": ["/main.Biz/Check"], "deny": ["/main.Biz/Check"]}
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for idx, tc := range []struct {
+		consumer string
+		method   string
+		allowed  bool
+	}{
+		{"biz_user", "/main.Biz/Check", true},
+		{"biz_user", "/main.Biz/Test", false},
+		{"biz_admin", "/main.Biz/Check", true},
+		{"biz_admin", "/main.Biz/Test", false},
+		{"tie", "/main.Biz/Check", false},
+		{"unknown", "/main.Biz/Check", false},
+	} {
+		if allowed := policy.isAllowed(tc.consumer, tc.method); allowed != tc.allowed {
+			t.Fatalf("[%d] %s %s: expected %v, got %v", idx, tc.consumer, tc.method, tc.allowed, allowed)
+		}
+	}
+
+	if _, err := parseAcl([]byte(`{"biz_admin": {"allow": ["/main.Biz/*"], "dney": ["/main.Biz/Test"]}}`)); err == nil {
+		t.Fatalf("expected error on unknown field, have nil")
+	}
+}
+
+func TestACLPatterns(t *testing.T) {
+	policy, err := parseAcl([]byte(`{
+	"biz_admin": ["/main.Biz/*"],
+	"checker":   ["/main.*/Check"],
+	"root":      {"allow": ["*"], "deny": ["/main.Admin/*"]},
+	"*":         ["/main.Biz/Check"]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for idx, tc := range []struct {
+		consumer string
+		method   string
+		allowed  bool
+	}{
+		{"biz_admin", "/main.Biz/Test", true},
+		{"biz_admin", "/main.Admin/Logging", false},
+		{"checker", "/main.Biz/Check", true},
+		{"checker", "/main.Admin/Check", true},
+		{"checker", "/main.Biz/Add", false},
+		{"checker", "/other.Biz/Check", false},
+		{"root", "/main.Biz/Add", true},
+		{"root", "/main.Admin/Statistics", false},
+		{"unknown", "/main.Biz/Check", true},
+		{"unknown", "/main.Biz/Add", false},
+	} {
+		if allowed := policy.isAllowed(tc.consumer, tc.method); allowed != tc.allowed {
+			t.Fatalf("[%d] %s %s: expected %v, got %v", idx, tc.consumer, tc.method, tc.allowed, allowed)
+		}
+	}
+}
+
+func TestACLRoles(t *testing.T) {
+	policy, err := parseAcl([]byte(`{
+	"version": 2,
+	"roles": {
+		"reader":     ["/main.Biz/Check"],
+		"biz_writer": {"allow": ["/main.Biz/Add"], "inherits": ["reader"]},
+		"biz_tester": {"allow": ["/main.Biz/Test"], "inherits": ["biz_writer"]}
+	},
+	"consumers": {
+		"biz_user":  {"roles": ["biz_writer"]},
+		"biz_admin": {"roles": ["biz_tester"], "deny": ["/main.Biz/Add"]},
+		"logger":    ["/main.Admin/Logging"]
+	}
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for idx, tc := range []struct {
+		consumer string
+		method   string
+		allowed  bool
+	}{
+		{"biz_user", "/main.Biz/Check", true},
+		{"biz_user", "/main.Biz/Add", true},
+		{"biz_user", "/main.Biz/Test", false},
+		{"biz_admin", "/main.Biz/Check", true},
+		{"biz_admin", "/main.Biz/Test", true},
+		{"biz_admin", "/main.Biz/Add", false},
+		{"logger", "/main.Admin/Logging", true},
+		{"reader", "/main.Biz/Check", false},
+	} {
+		if allowed := policy.isAllowed(tc.consumer, tc.method); allowed != tc.allowed {
+			t.Fatalf("[%d] %s %s: expected %v, got %v", idx, tc.consumer, tc.method, tc.allowed, allowed)
+		}
+	}
+
+	for idx, data := range []string{
+		`{"version": 2, "roles": {"a": {"inherits": ["b"]}, "b": {"inherits": ["a"]}}, "consumers": {}}`,
+		`{"version": 2, "roles": {}, "consumers": {"biz_user": {"roles": ["unknown"]}}}`,
+		`{"version": 2, "roles": {"a": {"roles": ["b"]}, "b": []}, "consumers": {}}`,
+		`{"version": 3, "roles": {}, "consumers": {}}`,
+		`{"version": 2, "consumers": {}, "biz_user": ["/main.Biz/Check"]}`,
+		`{"roles": {"reader": ["/main.Biz/Check"]}, "consumers": {"biz_user": {"roles": ["reader"]}}}`,
+		`{"biz_user": {"roles": ["reader"]}}`,
+	} {
+		if _, err := parseAcl([]byte(data)); err == nil {
+			t.Fatalf("[%d] expected error on bad acl %s", idx, data)
+		}
+	}
+
+	// without a version, consumers named like the sections of a document with roles are plain consumers
+	policy, err = parseAcl([]byte(`{"consumers": {"allow": ["/main.Biz/Check"]}, "roles": ["/main.Biz/Add"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !policy.isAllowed("consumers", "/main.Biz/Check") || !policy.isAllowed("roles", "/main.Biz/Add") {
+		t.Fatalf("expected consumers named consumers and roles to be allowed")
+	}
+}
+
+func TestACLExplain(t *testing.T) {
+	acl := `{
+	"version": 2,
+	"roles": {
+		"biz_all": ["/main.Biz/*"]
+	},
+	"consumers": {
+		"biz_admin": {"roles": ["biz_all"], "deny": ["/main.Biz/Test"]},
+		"admin":     ["/main.Admin/Explain"],
+		"*":         ["/main.Biz/Check"]
+	}
+}`
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, acl, Config{})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	adm := NewAdminClient(conn)
+
+	reply, err := adm.Explain(getConsumerCtx("admin"), &ExplainRequest{Consumer: "biz_admin", Method: "/main.Biz/Test"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply.Allowed || len(reply.Rules) != 2 || reply.Reason == "" {
+		t.Fatalf("expected a denial with 2 rules, have %v", reply)
+	}
+	if rule := reply.Rules[0]; rule.Pattern != "/main.Biz/Test" || !rule.Deny || rule.Entry != "biz_admin" || rule.Role != "" {
+		t.Fatalf("bad deciding rule: %v", rule)
+	}
+	if rule := reply.Rules[1]; rule.Pattern != "/main.Biz/*" || rule.Deny || rule.Role != "biz_all" {
+		t.Fatalf("bad overridden rule: %v", rule)
+	}
+
+	reply, err = adm.Explain(getConsumerCtx("admin"), &ExplainRequest{Consumer: "unknown", Method: "/main.Biz/Check"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reply.Allowed || len(reply.Rules) != 1 || reply.Rules[0].Entry != "*" {
+		t.Fatalf("expected to be allowed by the default entry, have %v", reply)
+	}
+
+	reply, err = adm.Explain(getConsumerCtx("admin"), &ExplainRequest{Consumer: "admin", Method: "/main.Biz/Add"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply.Allowed || len(reply.Rules) != 0 {
+		t.Fatalf("expected no matching rules, have %v", reply)
+	}
+
+	if _, err := adm.Explain(getConsumerCtx("admin"), &ExplainRequest{Consumer: "admin"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
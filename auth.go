@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//tokenHeader is a JOSE header of a token
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+//tokenClaims are claims of a token, the subject is a consumer name
+type tokenClaims struct {
+	Sub string `json:"sub"`
+	Exp int64  `json:"exp"`
+	Iat int64  `json:"iat,omitempty"`
+}
+
+//IssueToken makes a JWT signed with HMAC-SHA256 for a consumer.
+//A client passes it in the "authorization" metadata as "Bearer <token>"
+func IssueToken(key []byte, kid string, consumer string, ttl time.Duration) (string, error) {
+	if consumer == "" {
+		return "", errors.New("empty consumer")
+	}
+	now := time.Now()
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(tokenClaims{Sub: consumer, Exp: now.Add(ttl).Unix(), Iat: now.Unix()})
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signToken(key, signed)), nil
+}
+
+//signToken returns HMAC-SHA256 of the signed part of a token
+func signToken(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+//verifyToken checks the signature and the expiry of a token and returns its consumer.
+//If the token has no key id, keys must contain the only key
+func verifyToken(keys map[string][]byte, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+	header := tokenHeader{}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return "", fmt.Errorf("malformed token header: %v", err)
+	}
+	if header.Alg != "HS256" {
+		return "", fmt.Errorf("unsupported token algorithm '%s'", header.Alg)
+	}
+	key, found := keys[header.Kid]
+	if !found && header.Kid == "" && len(keys) == 1 {
+		for _, key = range keys {
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("unknown token key '%s'", header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed token signature: %v", err)
+	}
+	if !hmac.Equal(signature, signToken(key, parts[0]+"."+parts[1])) {
+		return "", errors.New("bad token signature")
+	}
+	claims := tokenClaims{}
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("malformed token claims: %v", err)
+	}
+	if claims.Sub == "" {
+		return "", errors.New("token has no consumer")
+	}
+	if claims.Exp == 0 || now.Unix() >= claims.Exp {
+		return "", errors.New("token is expired")
+	}
+	return claims.Sub, nil
+}
+
+//decodeTokenPart unmarshals a base64url encoded JSON part of a token
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+//authenticate returns the consumer of a call.
+//With mTLS the consumer is taken from the client certificate.
+//Without configured auth keys the "consumer" metadata is trusted,
+//otherwise the consumer is taken from a verified bearer token
+func (m *MsCtx) authenticate(ctx context.Context) (string, error) {
+	if m.cfg.TLSClientCAFile != "" {
+		consumer, err := m.consumerFromPeer(ctx)
+		if err != nil {
+			return "", status.Error(codes.Unauthenticated, err.Error())
+		}
+		return consumer, nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no metadata from you")
+	}
+	if len(m.cfg.AuthKeys) == 0 {
+		return getConsumer(md)
+	}
+
+	values := md["authorization"]
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "no token from you")
+	}
+	const prefix = "bearer "
+	if len(values[0]) <= len(prefix) || !strings.EqualFold(values[0][:len(prefix)], prefix) {
+		return "", status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+	}
+	consumer, err := verifyToken(m.cfg.AuthKeys, values[0][len(prefix):], time.Now())
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	return consumer, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// getTokenCtx returns a context with a bearer token
+func getTokenCtx(token string) context.Context {
+	md := metadata.Pairs(
+		"authorization", "Bearer "+token,
+	)
+	return metadata.NewOutgoingContext(context.Background(), md)
+}
+
+func TestVerifyToken(t *testing.T) {
+	keys := map[string][]byte{"k1": []byte("secret1"), "k2": []byte("secret2")}
+	now := time.Now()
+
+	token, err := IssueToken(keys["k1"], "k1", "biz_user", time.Minute)
+	if err != nil {
+		t.Fatalf("cant issue token: %v", err)
+	}
+	consumer, err := verifyToken(keys, token, now)
+	if err != nil || consumer != "biz_user" {
+		t.Fatalf("expected biz_user, got %q %v", consumer, err)
+	}
+
+	if _, err := verifyToken(keys, token, now.Add(2*time.Minute)); err == nil {
+		t.Fatalf("expected error on expired token")
+	}
+	wrongKey, _ := IssueToken(keys["k2"], "k1", "biz_admin", time.Minute)
+	if _, err := verifyToken(keys, wrongKey, now); err == nil {
+		t.Fatalf("expected error on bad signature")
+	}
+	unknownKey, _ := IssueToken(keys["k1"], "k3", "biz_admin", time.Minute)
+	if _, err := verifyToken(keys, unknownKey, now); err == nil {
+		t.Fatalf("expected error on unknown key")
+	}
+	noKid, _ := IssueToken(keys["k1"], "", "biz_admin", time.Minute)
+	if _, err := verifyToken(keys, noKid, now); err == nil {
+		t.Fatalf("expected error on token without key id and several keys")
+	}
+	if consumer, err := verifyToken(map[string][]byte{"k1": keys["k1"]}, noKid, now); err != nil || consumer != "biz_admin" {
+		t.Fatalf("expected biz_admin with the only key, got %q %v", consumer, err)
+	}
+	if _, err := verifyToken(keys, "a.b", now); err == nil {
+		t.Fatalf("expected error on malformed token")
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	key := []byte("secret")
+	ctx, finish := context.WithCancel(context.Background())
+	msCtx, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+		AuthKeys: map[string][]byte{"main": key},
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	loggerToken, _ := IssueToken(key, "main", "logger", time.Minute)
+	logStream, err := adm.Logging(getTokenCtx(loggerToken), &LogRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+
+	// the consumer metadata is not trusted anymore
+	if _, err := biz.Test(getConsumerCtx("biz_admin"), &Nothing{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for consumer metadata, got %v", err)
+	}
+	evt, err := logStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evt.Consumer != "" || evt.Outcome != Outcome_UNAUTHENTICATED || !strings.Contains(evt.Reason, "biz_admin") {
+		t.Fatalf("expected the claimed consumer in the reason only, have %+v", evt)
+	}
+	if stat := msCtx.stats.snapshot(); stat.ByConsumer["biz_admin"] != 0 {
+		t.Fatalf("expected no calls of the claimed consumer, have %v", stat.ByConsumer)
+	}
+
+	adminToken, _ := IssueToken(key, "main", "biz_admin", time.Minute)
+	if _, err := biz.Test(getTokenCtx(adminToken), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	userToken, _ := IssueToken(key, "main", "biz_user", time.Minute)
+	if _, err := biz.Test(getTokenCtx(userToken), &Nothing{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for biz_user, got %v", err)
+	}
+	forged, _ := IssueToken([]byte("other"), "main", "biz_admin", time.Minute)
+	if _, err := biz.Test(getTokenCtx(forged), &Nothing{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for forged token, got %v", err)
+	}
+
+	statToken, _ := IssueToken(key, "main", "stat", time.Minute)
+	logger, err := adm.Logging(getTokenCtx(statToken), &LogRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := logger.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated on stream, got %v", err)
+	}
+}
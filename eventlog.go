@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+//defaultEventLogSegmentSize is the size after which a new segment of the event log is started by default
+const defaultEventLogSegmentSize = 4 << 20
+
+//eventLogSuffix is the suffix of segment files, a segment is named after the seq of its first event
+const eventLogSuffix = ".log"
+
+//eventLogQueueSize is how many events may wait to be written before a call waits for the disk
+const eventLogQueueSize = 1024
+
+//eventSegment is a file of the event log, it holds events from first up to the first event of the next segment
+type eventSegment struct {
+	first uint64
+	path  string
+}
+
+//eventLog is an append-only log of events kept in segment files.
+//Events are queued in seq order after MsCtx.Lock is released and written by a goroutine of the log,
+//so calls never wait for the disk unless the queue is full. A segment is a sequence of records,
+//each record is the size of an Event as uvarint followed by the Event itself
+type eventLog struct {
+	queueLock     *sync.Mutex   //guards queue and nextSeq
+	queued        *sync.Cond    //signalled when an event is queued or the log is closed
+	queue         chan *Event   //nil once the log is closed
+	nextSeq       uint64        //the seq of the next event to queue
+	done          chan struct{} //closed when every queued event is written
+	lock          *sync.Mutex   //guards the fields below, it is held while an event is written
+	written       *sync.Cond    //signalled when an event is written
+	dir           string
+	segmentSize   int64
+	retentionSize int64
+	retentionAge  time.Duration
+	segments      []eventSegment
+	active        *os.File
+	activeSize    int64
+	lastSeq       uint64 //the last event written or given up on
+	closed        bool
+}
+
+//openEventLog opens the event log in cfg.EventLogDir and recovers the seq of the last event written.
+//A partial record left at the end of the log by a crash is cut off
+func openEventLog(cfg Config) (*eventLog, error) {
+	l := &eventLog{
+		queueLock:     &sync.Mutex{},
+		queue:         make(chan *Event, eventLogQueueSize),
+		done:          make(chan struct{}),
+		lock:          &sync.Mutex{},
+		dir:           cfg.EventLogDir,
+		segmentSize:   cfg.EventLogSegmentSize,
+		retentionSize: cfg.EventLogRetentionSize,
+		retentionAge:  cfg.EventLogRetentionAge,
+	}
+	if l.segmentSize <= 0 {
+		l.segmentSize = defaultEventLogSegmentSize
+	}
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, eventLogSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, eventLogSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		l.segments = append(l.segments, eventSegment{first: first, path: filepath.Join(l.dir, name)})
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].first < l.segments[j].first })
+	l.queued = sync.NewCond(l.queueLock)
+	l.written = sync.NewCond(l.lock)
+	if len(l.segments) == 0 {
+		l.nextSeq = 1
+		go l.run(l.queue)
+		return l, nil
+	}
+
+	last := l.segments[len(l.segments)-1]
+	l.lastSeq = last.first - 1
+	size, err := readEventSegment(last.path, func(event *Event) bool {
+		l.lastSeq = event.Seq
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Truncate(last.path, size); err != nil {
+		return nil, err
+	}
+	l.active, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.activeSize = size
+	l.nextSeq = l.lastSeq + 1
+	l.enforceRetention(time.Now())
+	go l.run(l.queue)
+	return l, nil
+}
+
+//readEventSegment calls visit for every event of a segment until visit returns false.
+//It returns the size of the complete records read, a partial record at the end is not an error
+func readEventSegment(path string, visit func(*Event) bool) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	lengthBuf := make([]byte, binary.MaxVarintLen64)
+	var offset int64
+	for {
+		size, err := binary.ReadUvarint(reader)
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return offset, err
+			}
+			return offset, nil
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return offset, err
+			}
+			return offset, nil
+		}
+		event := &Event{}
+		if err := proto.Unmarshal(data, event); err != nil {
+			log.Println("broken record in event log:", path, offset, err)
+			return offset, nil
+		}
+		offset += int64(binary.PutUvarint(lengthBuf, size)) + int64(size)
+		if !visit(event) {
+			return offset, nil
+		}
+	}
+}
+
+//enqueue queues an event to be written, it is called without MsCtx.Lock once the event has its seq.
+//The event waits for the events before it to be queued, so events are written in seq order.
+//It waits for the disk only if the disk is too far behind, audit events are never dropped
+func (l *eventLog) enqueue(event *Event) error {
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+	for l.queue != nil && l.nextSeq != event.Seq {
+		l.queued.Wait()
+	}
+	if l.queue == nil {
+		return errors.New("event log is closed")
+	}
+	select {
+	case l.queue <- event:
+	default:
+		log.Println("event log is behind, waiting for the disk")
+		l.queue <- event
+	}
+	l.nextSeq++
+	l.queued.Broadcast()
+	return nil
+}
+
+//run writes queued events until the queue is closed, then closes the active segment
+func (l *eventLog) run(queue chan *Event) {
+	defer close(l.done)
+	for event := range queue {
+		l.lock.Lock()
+		if err := l.append(event); err != nil {
+			log.Println("can't write event log:", event.Seq, err)
+		}
+		l.lastSeq = event.Seq
+		l.written.Broadcast()
+		l.lock.Unlock()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.active != nil {
+		if err := closeEventSegment(l.active); err != nil {
+			log.Println("can't close event log segment:", err)
+		}
+		l.active = nil
+	}
+	l.closed = true
+	l.written.Broadcast()
+}
+
+//append writes an event to the log starting a new segment if the active one is full
+func (l *eventLog) append(event *Event) error {
+	if l.active == nil || l.activeSize >= l.segmentSize {
+		if err := l.rotate(event.Seq); err != nil {
+			return err
+		}
+	}
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+	record := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	record = append(record[:binary.PutUvarint(record, uint64(len(data)))], data...)
+	written, err := l.active.Write(record)
+	l.activeSize += int64(written)
+	return err
+}
+
+//closeEventSegment flushes a segment to the disk and closes it
+func closeEventSegment(file *os.File) error {
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//rotate closes the active segment and starts a new one with the event first
+func (l *eventLog) rotate(first uint64) error {
+	if l.active != nil {
+		if err := closeEventSegment(l.active); err != nil {
+			log.Println("can't close event log segment:", err)
+		}
+		l.active = nil
+	}
+	segment := eventSegment{first: first, path: filepath.Join(l.dir, fmt.Sprintf("%020d%s", first, eventLogSuffix))}
+	file, err := os.OpenFile(segment.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	l.active = file
+	l.activeSize = 0
+	if n := len(l.segments); n == 0 || l.segments[n-1].first != first {
+		l.segments = append(l.segments, segment)
+	}
+	l.enforceRetention(time.Now())
+	return nil
+}
+
+//enforceRetention removes the oldest segments while the log is larger than the retention size
+//or while they are older than the retention age. The active segment is never removed
+func (l *eventLog) enforceRetention(now time.Time) {
+	if l.retentionSize <= 0 && l.retentionAge <= 0 {
+		return
+	}
+	sizes := make([]int64, len(l.segments))
+	modified := make([]time.Time, len(l.segments))
+	var total int64
+	for i, segment := range l.segments {
+		info, err := os.Stat(segment.path)
+		if err != nil {
+			continue
+		}
+		sizes[i] = info.Size()
+		modified[i] = info.ModTime()
+		total += sizes[i]
+	}
+	removed := 0
+	for i := 0; i < len(l.segments)-1; i++ {
+		tooLarge := l.retentionSize > 0 && total > l.retentionSize
+		tooOld := l.retentionAge > 0 && now.Sub(modified[i]) > l.retentionAge
+		if !tooLarge && !tooOld {
+			break
+		}
+		if err := os.Remove(l.segments[i].path); err != nil && !os.IsNotExist(err) {
+			log.Println("can't remove event log segment:", l.segments[i].path, err)
+			break
+		}
+		log.Println("event log segment removed:", l.segments[i].path)
+		total -= sizes[i]
+		removed++
+	}
+	l.segments = l.segments[removed:]
+}
+
+//expire removes the segments which are out of the retention
+func (l *eventLog) expire(now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.enforceRetention(now)
+}
+
+//segmentsUpTo waits until the events up to seq are written and returns the segments holding them
+func (l *eventLog) segmentsUpTo(seq uint64) []eventSegment {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for l.lastSeq < seq && !l.closed {
+		l.written.Wait()
+	}
+	return append([]eventSegment{}, l.segments...)
+}
+
+//close writes the queued events and closes the active segment, no events are queued after that
+func (l *eventLog) close() {
+	l.queueLock.Lock()
+	if l.queue != nil {
+		close(l.queue)
+		l.queue = nil
+		l.queued.Broadcast()
+	}
+	l.queueLock.Unlock()
+	<-l.done
+}
+
+//replayEventLog sends events of the segments with seq after the cursor up to upTo which pass the filter.
+//Segments removed by the retention in the meantime are skipped, the client sees a gap in seq then
+func replayEventLog(segments []eventSegment, after uint64, upTo uint64, filter *logFilter, send func(*Event) error) error {
+	var sendErr error
+	for i, segment := range segments {
+		if i+1 < len(segments) && segments[i+1].first <= after+1 {
+			continue
+		}
+		if segment.first > upTo {
+			break
+		}
+		done := false
+		_, err := readEventSegment(segment.path, func(event *Event) bool {
+			if event.Seq <= after {
+				return true
+			}
+			if event.Seq > upTo {
+				done = true
+				return false
+			}
+			if filter.matches(event) {
+				sendErr = send(event)
+			}
+			return sendErr == nil
+		})
+		if sendErr != nil {
+			return sendErr
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	return nil
+}
+
+//expireEvents removes expired segments of the event log every interval until ctx is done
+func (m *MsCtx) expireEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.events.expire(now)
+		}
+	}
+}
+
+//closeEventLog closes the event log when the microservice has stopped
+func (m *MsCtx) closeEventLog() {
+	if m.events == nil {
+		return
+	}
+	m.events.close()
+}
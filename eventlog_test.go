@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventLogSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := Config{EventLogDir: dir, EventLogSegmentSize: 64}
+
+	events, err := openEventLog(cfg)
+	if err != nil {
+		t.Fatalf("cant open event log: %v", err)
+	}
+	for i := 1; i <= 10; i++ {
+		event := &Event{Seq: uint64(i), Consumer: "biz_user", Method: "/main.Biz/Check"}
+		if err := events.append(event); err != nil {
+			t.Fatalf("[%d] cant append: %v", i, err)
+		}
+	}
+	if len(events.segments) < 2 {
+		t.Fatalf("expected several segments, have %v", events.segments)
+	}
+	events.close()
+
+	// a partial record left by a crash is cut off
+	last := events.segments[len(events.segments)-1].path
+	file, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("cant open segment: %v", err)
+	}
+	file.Write([]byte{42, 1, 2})
+	file.Close()
+
+	events, err = openEventLog(cfg)
+	if err != nil {
+		t.Fatalf("cant reopen event log: %v", err)
+	}
+	defer events.close()
+	if events.lastSeq != 10 {
+		t.Fatalf("expected last seq 10, have %d", events.lastSeq)
+	}
+	if err := events.append(&Event{Seq: 11}); err != nil {
+		t.Fatalf("cant append after reopen: %v", err)
+	}
+
+	filter, _ := newLogFilter(&LogRequest{})
+	have := []uint64{}
+	err = replayEventLog(events.segmentsUpTo(10), 4, 10, filter, func(event *Event) error {
+		have = append(have, event.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cant replay: %v", err)
+	}
+	if len(have) != 6 || have[0] != 5 || have[5] != 10 {
+		t.Fatalf("expected events 5..10, have %v", have)
+	}
+}
+
+func TestEventLogQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	events, err := openEventLog(Config{EventLogDir: dir, EventLogSegmentSize: 64})
+	if err != nil {
+		t.Fatalf("cant open event log: %v", err)
+	}
+	for i := 1; i <= eventLogQueueSize+10; i++ {
+		if err := events.enqueue(&Event{Seq: uint64(i), Consumer: "biz_user"}); err != nil {
+			t.Fatalf("[%d] cant enqueue: %v", i, err)
+		}
+	}
+
+	// queued events are on the disk before they are replayed
+	filter, _ := newLogFilter(&LogRequest{})
+	count := 0
+	err = replayEventLog(events.segmentsUpTo(eventLogQueueSize+10), 0, eventLogQueueSize+10, filter, func(event *Event) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cant replay: %v", err)
+	}
+	if count != eventLogQueueSize+10 {
+		t.Fatalf("expected %d events, have %d", eventLogQueueSize+10, count)
+	}
+
+	// an event queued before the one ahead of it waits for its turn
+	last := uint64(eventLogQueueSize + 12)
+	queued := make(chan error)
+	go func() {
+		queued <- events.enqueue(&Event{Seq: last})
+	}()
+	if err := events.enqueue(&Event{Seq: last - 1}); err != nil {
+		t.Fatalf("cant enqueue: %v", err)
+	}
+	if err := <-queued; err != nil {
+		t.Fatalf("cant enqueue: %v", err)
+	}
+	have := []uint64{}
+	err = replayEventLog(events.segmentsUpTo(last), last-2, last, filter, func(event *Event) error {
+		have = append(have, event.Seq)
+		return nil
+	})
+	if err != nil || len(have) != 2 || have[0] != last-1 || have[1] != last {
+		t.Fatalf("expected events %d and %d in order, have %v, %v", last-1, last, have, err)
+	}
+
+	events.close()
+	if err := events.enqueue(&Event{Seq: last + 1}); err == nil {
+		t.Fatalf("expected an error on a closed log")
+	}
+	if events.active != nil || !events.closed {
+		t.Fatalf("expected the active segment to be closed")
+	}
+}
+
+func TestEventLogRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := Config{EventLogDir: dir, EventLogSegmentSize: 64, EventLogRetentionSize: 200}
+
+	events, err := openEventLog(cfg)
+	if err != nil {
+		t.Fatalf("cant open event log: %v", err)
+	}
+	defer events.close()
+	for i := 1; i <= 50; i++ {
+		if err := events.append(&Event{Seq: uint64(i), Consumer: "biz_user"}); err != nil {
+			t.Fatalf("[%d] cant append: %v", i, err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+eventLogSuffix))
+	if len(files) != len(events.segments) {
+		t.Fatalf("expected %d files, have %v", len(events.segments), files)
+	}
+	var total int64
+	for _, file := range files {
+		info, _ := os.Stat(file)
+		total += info.Size()
+	}
+	if total > 200+64 || events.segments[0].first == 1 {
+		t.Fatalf("expected old segments to be removed, have %d bytes in %v", total, events.segments)
+	}
+
+	events.retentionAge = time.Hour
+	events.enforceRetention(time.Now().Add(2 * time.Hour))
+	if len(events.segments) != 1 {
+		t.Fatalf("expected only the active segment to be kept, have %v", events.segments)
+	}
+}
+
+func TestLoggingResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := Config{EventLogDir: dir}
+	calls := func(n int) {
+		conn := getGrpcConn(t)
+		defer conn.Close()
+		biz := NewBizClient(conn)
+		for i := 0; i < n; i++ {
+			if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+				t.Fatalf("[%d] unexpected error: %v", i, err)
+			}
+		}
+	}
+
+	ctx, finish := context.WithCancel(context.Background())
+	_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, cfg)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	calls(3)
+	finish()
+	wait(1)
+
+	// seq goes on after a restart
+	ctx, finish = context.WithCancel(context.Background())
+	_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, cfg)
+	if err != nil {
+		t.Fatalf("cant start server again: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+	calls(2)
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	adm := NewAdminClient(conn)
+	logStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{Consumers: []string{"biz_user"}, ResumeAfter: 2, Outcomes: []Outcome{Outcome_ALLOWED, Outcome_COMPLETED}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// every call makes an event before and after it is handled
+	for expected := uint64(3); expected <= 10; expected++ {
+		evt, err := logStream.Recv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if evt.Seq != expected {
+			t.Fatalf("expected event %d, have %v", expected, evt)
+		}
+	}
+	wait(1)
+	calls(1)
+	evt, err := logStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evt.Seq <= 10 || evt.Consumer != "biz_user" {
+		t.Fatalf("expected a live event after the resumed ones, have %v", evt)
+	}
+}
+
+func TestLoggingResumeCatchUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewMsCtx()
+	m.events, err = openEventLog(Config{EventLogDir: dir})
+	if err != nil {
+		t.Fatalf("cant open event log: %v", err)
+	}
+	defer m.closeEventLog()
+	for i := 0; i < 100; i++ {
+		m.logEvent(&Event{Consumer: "biz_user"})
+	}
+
+	// events logged during a long replay are replayed too instead of overflowing the buffer
+	req := &LogRequest{ResumeAfter: 1, BufferSize: 2, Overflow: OverflowPolicy_DISCONNECT}
+	filter, _ := newLogFilter(req)
+	subscriber := newLogSubscriber(req, filter, Config{})
+	have := []uint64{}
+	send := func(event *Event) error {
+		have = append(have, event.Seq)
+		if event.Seq <= 10 {
+			m.logEvent(&Event{Consumer: "biz_user"})
+		}
+		return nil
+	}
+	_, replay, err := m.addLogger(subscriber, req, send)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := replay.send(filter, send); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.logEvent(&Event{Consumer: "biz_user"})
+	m.logEvent(&Event{Consumer: "biz_user"})
+	if len(have) != 108 {
+		t.Fatalf("expected events 2..109, have %v", have)
+	}
+	for i, seq := range have {
+		if seq != uint64(i+2) {
+			t.Fatalf("expected events 2..109 in order, have %v", have)
+		}
+	}
+	select {
+	case <-subscriber.gone:
+		t.Fatalf("expected the logger to keep up")
+	default:
+	}
+	if live := <-subscriber.events; live.Seq != 110 {
+		t.Fatalf("expected live event 110, have %v", live)
+	}
+}
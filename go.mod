@@ -3,7 +3,7 @@ module hw7_microservice
 go 1.14
 
 require (
-	github.com/golang/protobuf v1.4.0 // indirect
+	github.com/golang/protobuf v1.4.0
 	google.golang.org/grpc v1.29.1
 	google.golang.org/protobuf v1.21.0
 )
@@ -0,0 +1,50 @@
+package main
+
+//defaultEventHistorySize is how many recent events are kept for replay by default
+const defaultEventHistorySize = 1024
+
+//eventHistory is a ring of recent events, it is guarded by MsCtx.Lock
+type eventHistory struct {
+	events []*Event
+	next   int
+	full   bool
+}
+
+//newEventHistory helps to make an eventHistory
+func newEventHistory(size int) *eventHistory {
+	if size <= 0 {
+		size = defaultEventHistorySize
+	}
+	return &eventHistory{events: make([]*Event, size)}
+}
+
+//add puts an event into the ring replacing the oldest one if the ring is full
+func (h *eventHistory) add(event *Event) {
+	h.events[h.next] = event
+	h.next++
+	if h.next == len(h.events) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+//replay returns events which pass the filter, oldest first.
+//If last is not zero, only the last matching events are returned,
+//if since is not zero, only events with a timestamp not before since are returned
+//and only events with a seq after the cursor are returned
+func (h *eventHistory) replay(filter *logFilter, last int, since int64, after uint64) []*Event {
+	ordered := h.events[:h.next]
+	if h.full {
+		ordered = append(append([]*Event{}, h.events[h.next:]...), h.events[:h.next]...)
+	}
+	result := []*Event{}
+	for _, event := range ordered {
+		if event.Timestamp >= since && event.Seq > after && filter.matches(event) {
+			result = append(result, event)
+		}
+	}
+	if last != 0 && len(result) > last {
+		result = result[len(result)-last:]
+	}
+	return result
+}
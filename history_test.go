@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEventHistory(t *testing.T) {
+	filter, _ := newLogFilter(&LogRequest{})
+	history := newEventHistory(3)
+	for i := 1; i <= 5; i++ {
+		history.add(&Event{Seq: uint64(i), Timestamp: int64(i * 10)})
+	}
+	seqs := func(events []*Event) []uint64 {
+		result := []uint64{}
+		for _, event := range events {
+			result = append(result, event.Seq)
+		}
+		return result
+	}
+	for idx, tc := range []struct {
+		last     int
+		since    int64
+		after    uint64
+		expected []uint64
+	}{
+		{0, 0, 0, []uint64{3, 4, 5}},
+		{2, 0, 0, []uint64{4, 5}},
+		{0, 40, 0, []uint64{4, 5}},
+		{1, 30, 0, []uint64{5}},
+		{10, 60, 0, []uint64{}},
+		{0, 0, 3, []uint64{4, 5}},
+		{0, 0, 5, []uint64{}},
+	} {
+		have := seqs(history.replay(filter, tc.last, tc.since, tc.after))
+		if len(have) != len(tc.expected) {
+			t.Fatalf("[%d] expected %v, have %v", idx, tc.expected, have)
+		}
+		for i := range have {
+			if have[i] != tc.expected[i] {
+				t.Fatalf("[%d] expected %v, have %v", idx, tc.expected, have)
+			}
+		}
+	}
+}
+
+func TestLoggingReplay(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	err := StartMyMicroservice(ctx, listenAddr, ACLData)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_admin"), &Nothing{})
+
+	logStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{
+		ReplayLast: 2,
+		Consumers:  []string{"biz_user", "biz_admin"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	biz.Check(getConsumerCtx("biz_admin"), &Nothing{})
+
+	expected := []string{"/main.Biz/Add", "/main.Biz/Test", "/main.Biz/Check"}
+	var lastSeq uint64
+	for idx, method := range expected {
+		evt, err := logStream.Recv()
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v, awaiting event", idx, err)
+		}
+		if evt.Method != method {
+			t.Fatalf("[%d] expected %s, have %s", idx, method, evt.Method)
+		}
+		if evt.Seq <= lastSeq {
+			t.Fatalf("[%d] sequence numbers must grow: %d after %d", idx, evt.Seq, lastSeq)
+		}
+		lastSeq = evt.Seq
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+//defaultLatencyBuckets returns the upper bounds of latency buckets used if the config has none
+func defaultLatencyBuckets() []time.Duration {
+	return []time.Duration{
+		time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+		10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+		100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+		time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+	}
+}
+
+//latencyBounds returns the sorted upper bounds of latency buckets in seconds
+func (cfg Config) latencyBounds() []float64 {
+	buckets := cfg.LatencyBuckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets()
+	}
+	bounds := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		bounds = append(bounds, bucket.Seconds())
+	}
+	sort.Float64s(bounds)
+	return bounds
+}
+
+//newLatencyHistogram makes an empty histogram, the last bucket counts calls slower than every bound
+func newLatencyHistogram(bounds []float64) *LatencyHistogram {
+	return &LatencyHistogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+//observeLatency counts a call in the bucket of the smallest bound not less than its duration
+func observeLatency(h *LatencyHistogram, duration time.Duration) {
+	seconds := duration.Seconds()
+	h.Counts[sort.SearchFloat64s(h.Bounds, seconds)]++
+	h.Count++
+	h.Sum += seconds
+}
+
+//summarizeLatency fills the percentiles of a histogram
+func summarizeLatency(h *LatencyHistogram) {
+	h.P50 = latencyQuantile(h, 0.5)
+	h.P90 = latencyQuantile(h, 0.9)
+	h.P99 = latencyQuantile(h, 0.99)
+}
+
+//latencyQuantile estimates a quantile assuming calls are spread evenly inside a bucket.
+//Calls slower than every bound are estimated by the largest bound
+func latencyQuantile(h *LatencyHistogram, q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := q * float64(h.Count)
+	var below uint64
+	for i, count := range h.Counts {
+		if count == 0 || float64(below+count) < rank {
+			below += count
+			continue
+		}
+		if i == len(h.Bounds) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		}
+		return lower + (h.Bounds[i]-lower)*(rank-float64(below))/float64(count)
+	}
+	if len(h.Bounds) == 0 {
+		return 0
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+//addLatencyStat counts the duration of a handled call of a consumer from a host
+func (m *MsCtx) addLatencyStat(consumer string, method string, host string, duration time.Duration) {
+	m.stats.addLatency(consumer, method, hostOf(host), duration, time.Now())
+}
+
+//countLatency counts the duration of a call in a Stat
+func countLatency(stat Stat, method string, duration time.Duration, bounds []float64) {
+	histogram, found := stat.LatencyByMethod[method]
+	if !found {
+		histogram = newLatencyHistogram(bounds)
+		stat.LatencyByMethod[method] = histogram
+	}
+	observeLatency(histogram, duration)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	bounds := Config{LatencyBuckets: []time.Duration{time.Second, 10 * time.Millisecond, 100 * time.Millisecond}}.latencyBounds()
+	if !reflect.DeepEqual(bounds, []float64{0.01, 0.1, 1}) {
+		t.Fatalf("expected sorted bounds in seconds, have %v", bounds)
+	}
+	histogram := newLatencyHistogram(bounds)
+	for _, tc := range []struct {
+		duration time.Duration
+		calls    int
+	}{
+		{5 * time.Millisecond, 50},
+		{50 * time.Millisecond, 40},
+		{500 * time.Millisecond, 9},
+		{2 * time.Second, 1},
+	} {
+		for i := 0; i < tc.calls; i++ {
+			observeLatency(histogram, tc.duration)
+		}
+	}
+	if !reflect.DeepEqual(histogram.Counts, []uint64{50, 40, 9, 1}) || histogram.Count != 100 {
+		t.Fatalf("bad counts %v of %d", histogram.Counts, histogram.Count)
+	}
+	summarizeLatency(histogram)
+	for name, tc := range map[string]struct{ have, want float64 }{
+		"p50":  {histogram.P50, 0.01},
+		"p90":  {histogram.P90, 0.1},
+		"p99":  {histogram.P99, 1},
+		"max":  {latencyQuantile(histogram, 1), 1},
+		"p25":  {latencyQuantile(histogram, 0.25), 0.005},
+		"none": {latencyQuantile(newLatencyHistogram(bounds), 0.5), 0},
+	} {
+		if math.Abs(tc.have-tc.want) > 1e-9 {
+			t.Fatalf("[%s] expected %v, have %v", name, tc.want, tc.have)
+		}
+	}
+}
+
+func TestLatencyStat(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+		LatencyBuckets: []time.Duration{time.Millisecond, time.Second},
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	statStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalSeconds: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+
+	stat, err := statStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	histogram := stat.LatencyByMethod["/main.Biz/Check"]
+	if histogram == nil {
+		t.Fatalf("expected latency of Check, have %v", stat.LatencyByMethod)
+	}
+	if histogram.Count != 2 || len(histogram.Counts) != 3 || !reflect.DeepEqual(histogram.Bounds, []float64{0.001, 1}) {
+		t.Fatalf("bad histogram of Check: %v", histogram)
+	}
+	if histogram.P50 <= 0 || histogram.P50 > histogram.P99 || histogram.P99 > 1 {
+		t.Fatalf("bad percentiles of Check: %v", histogram)
+	}
+}
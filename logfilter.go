@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net"
+	"path"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//logFilter selects events for a Logging subscriber, an empty condition matches every event.
+//Events about completed calls are selected only if the subscriber asked for the COMPLETED outcome
+type logFilter struct {
+	consumers     map[string]bool
+	methodPattern string
+	hosts         map[string]bool
+	outcomes      map[Outcome]bool
+}
+
+//newLogFilter validates a Logging request and builds a filter from it
+func newLogFilter(req *LogRequest) (*logFilter, error) {
+	filter := &logFilter{methodPattern: req.MethodPattern}
+	if filter.methodPattern == "*" {
+		filter.methodPattern = "/*/*"
+	}
+	if _, err := path.Match(filter.methodPattern, ""); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad method pattern '%s': %v", req.MethodPattern, err)
+	}
+	if len(req.Consumers) != 0 {
+		filter.consumers = make(map[string]bool, len(req.Consumers))
+		for _, consumer := range req.Consumers {
+			filter.consumers[consumer] = true
+		}
+	}
+	if len(req.Hosts) != 0 {
+		filter.hosts = make(map[string]bool, len(req.Hosts))
+		for _, host := range req.Hosts {
+			filter.hosts[host] = true
+		}
+	}
+	if len(req.Outcomes) != 0 {
+		filter.outcomes = make(map[Outcome]bool, len(req.Outcomes))
+		for _, outcome := range req.Outcomes {
+			filter.outcomes[outcome] = true
+		}
+	}
+	return filter, nil
+}
+
+//matches returns true if the event passes the filter.
+//A host matches either the whole "host:port" address of an event or its host part
+func (f *logFilter) matches(event *Event) bool {
+	if f.consumers != nil && !f.consumers[event.Consumer] {
+		return false
+	}
+	if f.methodPattern != "" {
+		if matched, _ := path.Match(f.methodPattern, event.Method); !matched {
+			return false
+		}
+	}
+	if f.hosts != nil && !f.hosts[event.Host] {
+		host, _, err := net.SplitHostPort(event.Host)
+		if err != nil || !f.hosts[host] {
+			return false
+		}
+	}
+	if f.outcomes == nil {
+		return event.Outcome != Outcome_COMPLETED
+	}
+	if !f.outcomes[event.Outcome] {
+		return false
+	}
+	return true
+}
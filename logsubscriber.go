@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+const (
+	//defaultLogBufferSize is how many events a logger may lag behind by default
+	defaultLogBufferSize = 64
+	//maxLogBufferSize limits the buffer size a logger may ask for
+	maxLogBufferSize = 65536
+)
+
+//logSubscriber is a buffered channel of a logger with a filter of events which the logger gets
+type logSubscriber struct {
+	dropped uint64 //events dropped since the last sent event, accessed atomically
+	events  chan *Event
+	filter  *logFilter
+	policy  OverflowPolicy
+	gone    chan struct{} //closed when the logger is disconnected for being too slow
+}
+
+//newLogSubscriber makes a logSubscriber for a Logging request, cfg provides defaults
+func newLogSubscriber(req *LogRequest, filter *logFilter, cfg Config) *logSubscriber {
+	size := int(req.BufferSize)
+	if size == 0 {
+		size = cfg.LogBufferSize
+	}
+	if size <= 0 {
+		size = defaultLogBufferSize
+	}
+	if size > maxLogBufferSize {
+		size = maxLogBufferSize
+	}
+	policy := req.Overflow
+	if policy == OverflowPolicy_POLICY_DEFAULT {
+		policy = cfg.LogOverflowPolicy
+	}
+	if policy == OverflowPolicy_POLICY_DEFAULT {
+		policy = OverflowPolicy_DROP_OLDEST
+	}
+	return &logSubscriber{
+		events: make(chan *Event, size),
+		filter: filter,
+		policy: policy,
+		gone:   make(chan struct{}),
+	}
+}
+
+//offer puts an event into the buffer without blocking and applies the overflow policy
+//if the buffer is full. It returns false if the logger has to be disconnected
+func (s *logSubscriber) offer(event *Event) bool {
+	select {
+	case s.events <- event:
+		return true
+	default:
+	}
+	switch s.policy {
+	case OverflowPolicy_DROP_NEWEST:
+	case OverflowPolicy_DISCONNECT:
+		atomic.AddUint64(&s.dropped, 1)
+		close(s.gone)
+		return false
+	default:
+		select {
+		case <-s.events:
+		default:
+		}
+		select {
+		case s.events <- event:
+		default:
+			//the buffer was refilled by a concurrent sender, drop the newest then
+		}
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	return true
+}
+
+//takeDropped returns how many events have been dropped since the previous call
+func (s *logSubscriber) takeDropped() uint64 {
+	return atomic.SwapUint64(&s.dropped, 0)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLogSubscriberOverflow(t *testing.T) {
+	filter, _ := newLogFilter(&LogRequest{})
+	for _, tc := range []struct {
+		policy    OverflowPolicy
+		connected bool
+		buffered  []string
+	}{
+		{OverflowPolicy_POLICY_DEFAULT, true, []string{"/main.Biz/Add", "/main.Biz/Test"}},
+		{OverflowPolicy_DROP_OLDEST, true, []string{"/main.Biz/Add", "/main.Biz/Test"}},
+		{OverflowPolicy_DROP_NEWEST, true, []string{"/main.Biz/Check", "/main.Biz/Add"}},
+		{OverflowPolicy_DISCONNECT, false, []string{"/main.Biz/Check", "/main.Biz/Add"}},
+	} {
+		subscriber := newLogSubscriber(&LogRequest{BufferSize: 2, Overflow: tc.policy}, filter, Config{})
+		connected := true
+		for _, method := range []string{"/main.Biz/Check", "/main.Biz/Add", "/main.Biz/Test"} {
+			connected = subscriber.offer(&Event{Method: method})
+		}
+		if connected != tc.connected {
+			t.Fatalf("[%v] expected connected %v, have %v", tc.policy, tc.connected, connected)
+		}
+		if dropped := subscriber.takeDropped(); dropped != 1 {
+			t.Fatalf("[%v] expected 1 dropped event, have %d", tc.policy, dropped)
+		}
+		if dropped := subscriber.takeDropped(); dropped != 0 {
+			t.Fatalf("[%v] expected the dropped counter to be reset, have %d", tc.policy, dropped)
+		}
+		for idx, method := range tc.buffered {
+			if evt := <-subscriber.events; evt.Method != method {
+				t.Fatalf("[%v %d] expected %s, have %s", tc.policy, idx, method, evt.Method)
+			}
+		}
+		select {
+		case <-subscriber.gone:
+			if tc.connected {
+				t.Fatalf("[%v] unexpected disconnect", tc.policy)
+			}
+		default:
+			if !tc.connected {
+				t.Fatalf("[%v] expected disconnect", tc.policy)
+			}
+		}
+	}
+}
+
+func TestLogSubscriberDefaults(t *testing.T) {
+	filter, _ := newLogFilter(&LogRequest{})
+	subscriber := newLogSubscriber(&LogRequest{}, filter, Config{})
+	if cap(subscriber.events) != defaultLogBufferSize || subscriber.policy != OverflowPolicy_DROP_OLDEST {
+		t.Fatalf("bad defaults: size %d policy %v", cap(subscriber.events), subscriber.policy)
+	}
+	subscriber = newLogSubscriber(&LogRequest{}, filter, Config{LogBufferSize: 5, LogOverflowPolicy: OverflowPolicy_DISCONNECT})
+	if cap(subscriber.events) != 5 || subscriber.policy != OverflowPolicy_DISCONNECT {
+		t.Fatalf("config defaults are ignored: size %d policy %v", cap(subscriber.events), subscriber.policy)
+	}
+	subscriber = newLogSubscriber(&LogRequest{BufferSize: maxLogBufferSize + 1}, filter, Config{})
+	if cap(subscriber.events) != maxLogBufferSize {
+		t.Fatalf("buffer size is not limited: %d", cap(subscriber.events))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//metricsPath is where the counters are exposed in the Prometheus text format
+const metricsPath = "/metrics"
+
+//timeouts of the metrics server, so that idle and slow clients don't hold connections forever
+const (
+	metricsReadTimeout  = 5 * time.Second
+	metricsWriteTimeout = 10 * time.Second
+	metricsIdleTimeout  = time.Minute
+)
+
+//serveMetrics serves the counters over HTTP until ctx is done
+func (m *MsCtx) serveMetrics(ctx context.Context, lis net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, m.handleMetrics)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: metricsReadTimeout,
+		ReadTimeout:       metricsReadTimeout,
+		WriteTimeout:      metricsWriteTimeout,
+		IdleTimeout:       metricsIdleTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		log.Println("closing metrics server")
+		server.Close()
+	}()
+	log.Println("starting metrics server at " + lis.Addr().String())
+	if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+		log.Println("metrics server failed:", err)
+	}
+}
+
+//handleMetrics writes the totals since the start of the microservice
+func (m *MsCtx) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := writeMetrics(w, m.stats.snapshot()); err != nil {
+		log.Println("can't write metrics:", err)
+	}
+}
+
+//writeMetrics writes counters and latency histograms in the Prometheus text format
+func writeMetrics(w io.Writer, stat *Stat) error {
+	b := &strings.Builder{}
+	writeCounter(b, "microservice_calls_total", "Calls by method.", "method", stat.ByMethod)
+	writeCounter(b, "microservice_consumer_calls_total", "Calls by consumer.", "consumer", stat.ByConsumer)
+	writeCounter(b, "microservice_host_calls_total", "Calls by peer host.", "host", stat.ByHost)
+
+	fmt.Fprintf(b, "# HELP microservice_handled_total Finished calls by method and status code.\n")
+	fmt.Fprintf(b, "# TYPE microservice_handled_total counter\n")
+	for _, method := range sortedKeys(stat.ByMethodCode) {
+		codes := stat.ByMethodCode[method].ByCode
+		for _, code := range sortedKeys(codes) {
+			fmt.Fprintf(b, "microservice_handled_total{method=%s,code=%s} %d\n", quoteLabel(method), quoteLabel(code), codes[code])
+		}
+	}
+
+	fmt.Fprintf(b, "# HELP microservice_latency_seconds Duration of handled calls by method.\n")
+	fmt.Fprintf(b, "# TYPE microservice_latency_seconds histogram\n")
+	for _, method := range sortedKeys(stat.LatencyByMethod) {
+		histogram := stat.LatencyByMethod[method]
+		label := quoteLabel(method)
+		var cumulative uint64
+		for i, bound := range histogram.Bounds {
+			cumulative += histogram.Counts[i]
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(b, "microservice_latency_seconds_bucket{method=%s,le=%q} %d\n", label, le, cumulative)
+		}
+		fmt.Fprintf(b, "microservice_latency_seconds_bucket{method=%s,le=\"+Inf\"} %d\n", label, histogram.Count)
+		fmt.Fprintf(b, "microservice_latency_seconds_sum{method=%s} %s\n", label, strconv.FormatFloat(histogram.Sum, 'g', -1, 64))
+		fmt.Fprintf(b, "microservice_latency_seconds_count{method=%s} %d\n", label, histogram.Count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//writeCounter writes a counter with one label
+func writeCounter(b *strings.Builder, name string, help string, label string, counts map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	for _, key := range sortedKeys(counts) {
+		fmt.Fprintf(b, "%s{%s=%s} %d\n", name, label, quoteLabel(key), counts[key])
+	}
+}
+
+//quoteLabel quotes a label value escaping backslashes, quotes and new lines as the text format requires
+func quoteLabel(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
+
+//sortedKeys returns keys of a map with string keys in order, so that the output is stable
+func sortedKeys(counts interface{}) []string {
+	var keys []string
+	switch counts := counts.(type) {
+	case map[string]uint64:
+		for key := range counts {
+			keys = append(keys, key)
+		}
+	case map[string]*CodeCounts:
+		for key := range counts {
+			keys = append(keys, key)
+		}
+	case map[string]*LatencyHistogram:
+		for key := range counts {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const metricsAddr = "127.0.0.1:8083"
+
+func TestMetrics(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+		MetricsAddr:    metricsAddr,
+		LatencyBuckets: []time.Duration{time.Second},
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_user"), &Nothing{})
+
+	resp, err := http.Get("http://" + metricsAddr + metricsPath)
+	if err != nil {
+		t.Fatalf("cant scrape metrics: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("cant read metrics: %v", err)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("bad content type %q", resp.Header.Get("Content-Type"))
+	}
+	for _, line := range []string{
+		`# TYPE microservice_calls_total counter`,
+		`microservice_calls_total{method="/main.Biz/Check"} 1`,
+		`microservice_calls_total{method="/main.Biz/Test"} 1`,
+		`microservice_consumer_calls_total{consumer="biz_user"} 2`,
+		`microservice_host_calls_total{host="127.0.0.1"} 2`,
+		`microservice_handled_total{method="/main.Biz/Check",code="OK"} 1`,
+		`microservice_handled_total{method="/main.Biz/Test",code="Unauthenticated"} 1`,
+		`# TYPE microservice_latency_seconds histogram`,
+		`microservice_latency_seconds_bucket{method="/main.Biz/Check",le="1"} 1`,
+		`microservice_latency_seconds_bucket{method="/main.Biz/Check",le="+Inf"} 1`,
+		`microservice_latency_seconds_count{method="/main.Biz/Check"} 1`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("expected %q in metrics\n%s", line, body)
+		}
+	}
+
+	// the endpoint stops together with the service
+	finish()
+	wait(1)
+	if resp, err := http.Get("http://" + metricsAddr + metricsPath); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected metrics to be stopped")
+	}
+}
+
+func TestQuoteLabel(t *testing.T) {
+	if have := quoteLabel("a\"b\\c\nd"); have != `"a\"b\\c\nd"` {
+		t.Fatalf("bad quoted label %s", have)
+	}
+}
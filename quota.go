@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//quotaAllMethods is the method key of a quota which counts calls of all methods together
+const quotaAllMethods = "*"
+
+//Quota limits calls per calendar day and month in UTC, zero means no limit
+type Quota struct {
+	Daily   uint64
+	Monthly uint64
+}
+
+//quotaCounter is the usage of a quota in the current day and month
+type quotaCounter struct {
+	Day       string `json:"day"`
+	DayUsed   uint64 `json:"day_used"`
+	Month     string `json:"month"`
+	MonthUsed uint64 `json:"month_used"`
+}
+
+//roll resets the counters if a calendar day or month has passed
+func (c *quotaCounter) roll(now time.Time) {
+	now = now.UTC()
+	if day := now.Format("2006-01-02"); c.Day != day {
+		c.Day, c.DayUsed = day, 0
+	}
+	if month := now.Format("2006-01"); c.Month != month {
+		c.Month, c.MonthUsed = month, 0
+	}
+}
+
+//exceeds returns true if one more call does not fit into the quota
+func (c *quotaCounter) exceeds(quota Quota) bool {
+	return (quota.Daily != 0 && c.DayUsed >= quota.Daily) || (quota.Monthly != 0 && c.MonthUsed >= quota.Monthly)
+}
+
+//quotaLedger keeps quota counters by consumer and method key.
+//If it has a path, the counters are loaded from the file and flushed back to it
+type quotaLedger struct {
+	lock      *sync.Mutex
+	flushLock *sync.Mutex //keeps flushes from writing the file at the same time
+	path      string
+	counters  map[quotaKey]*quotaCounter
+	dirty     bool
+}
+
+//newQuotaLedger helps to make an in-memory quotaLedger
+func newQuotaLedger() *quotaLedger {
+	return &quotaLedger{lock: &sync.Mutex{}, flushLock: &sync.Mutex{}, counters: make(map[quotaKey]*quotaCounter)}
+}
+
+//loadQuotaLedger makes a quotaLedger kept in a file, an empty path makes an in-memory ledger
+func loadQuotaLedger(path string) (*quotaLedger, error) {
+	ledger := newQuotaLedger()
+	ledger.path = path
+	if path == "" {
+		return ledger, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []quotaRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		//a ledger written before the records were introduced is an object keyed by "consumer method",
+		//methods have no spaces so the key is split at the last one
+		legacy := make(map[string]*quotaCounter)
+		if json.Unmarshal(data, &legacy) != nil {
+			return nil, err
+		}
+		for key, counter := range legacy {
+			i := strings.LastIndex(key, " ")
+			if i < 0 {
+				continue
+			}
+			records = append(records, quotaRecord{Consumer: key[:i], Method: key[i+1:], quotaCounter: *counter})
+		}
+	}
+	for _, record := range records {
+		counter := record.quotaCounter
+		ledger.counters[quotaKey{consumer: record.Consumer, method: record.Method}] = &counter
+	}
+	return ledger, nil
+}
+
+//quotaKey is a key of a counter in the ledger
+type quotaKey struct {
+	consumer string
+	method   string
+}
+
+//quotaRecord is a counter as it is written to the file
+type quotaRecord struct {
+	Consumer string `json:"consumer"`
+	Method   string `json:"method"`
+	quotaCounter
+}
+
+//counter returns the counter of a key rolled to now
+func (l *quotaLedger) counter(key quotaKey, now time.Time) *quotaCounter {
+	counter, found := l.counters[key]
+	if !found {
+		counter = &quotaCounter{}
+		l.counters[key] = counter
+	}
+	counter.roll(now)
+	return counter
+}
+
+//flush writes the counters to the file if they have changed.
+//The counters stay changed if they can't be written, so the next flush retries
+func (l *quotaLedger) flush() error {
+	l.flushLock.Lock()
+	defer l.flushLock.Unlock()
+	l.lock.Lock()
+	if l.path == "" || !l.dirty {
+		l.lock.Unlock()
+		return nil
+	}
+	records := make([]quotaRecord, 0, len(l.counters))
+	for key, counter := range l.counters {
+		records = append(records, quotaRecord{Consumer: key.consumer, Method: key.method, quotaCounter: *counter})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Consumer != records[j].Consumer {
+			return records[i].Consumer < records[j].Consumer
+		}
+		return records[i].Method < records[j].Method
+	})
+	data, err := json.Marshal(records)
+	l.dirty = false
+	l.lock.Unlock()
+	if err == nil {
+		err = writeFileAtomic(l.path, data)
+	}
+	if err != nil {
+		l.lock.Lock()
+		l.dirty = true
+		l.lock.Unlock()
+	}
+	return err
+}
+
+//flushEvery calls flush every interval until ctx is done, what names the flushed file in the log.
+//The last flush is done when the server has stopped, so that it counts the calls which were running
+func flushEvery(ctx context.Context, interval time.Duration, what string, flush func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				log.Println("can't flush", what+":", err)
+			}
+		}
+	}
+}
+
+//writeFileAtomic replaces a file with data so that a reader never sees a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+//lookupQuota returns a quota of a consumer for a method key, the "*" consumer is the default
+func (cfg Config) lookupQuota(consumer string, method string) (Quota, bool) {
+	if quota, found := cfg.Quotas[consumer][method]; found {
+		return quota, true
+	}
+	quota, found := cfg.Quotas[aclDefaultConsumer][method]
+	return quota, found
+}
+
+//chargeQuota counts a call against the quotas of a consumer.
+//The call is counted by none of the quotas if one of them is exceeded
+func (m *MsCtx) chargeQuota(consumer string, method string) error {
+	if len(m.cfg.Quotas) == 0 {
+		return nil
+	}
+	now := time.Now()
+	ledger := m.quotas
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+
+	counters := make([]*quotaCounter, 0, 2)
+	for _, key := range []string{method, quotaAllMethods} {
+		quota, found := m.cfg.lookupQuota(consumer, key)
+		if !found {
+			continue
+		}
+		counter := ledger.counter(quotaKey{consumer: consumer, method: key}, now)
+		if counter.exceeds(quota) {
+			return status.Errorf(codes.ResourceExhausted, "quota exceeded for '%s' on '%s'", consumer, key)
+		}
+		counters = append(counters, counter)
+	}
+	for _, counter := range counters {
+		counter.DayUsed++
+		counter.MonthUsed++
+	}
+	if len(counters) != 0 {
+		ledger.dirty = true
+	}
+	return nil
+}
+
+//GetQuota is an implementation GetQuota function of AdminServer interface.
+//It reports quotas and their usage of a consumer or of all consumers if the consumer is empty
+func (m *MsCtx) GetQuota(ctx context.Context, req *QuotaRequest) (*QuotaReport, error) {
+	keys := make(map[quotaKey]bool)
+	for consumer, quotas := range m.cfg.Quotas {
+		for method := range quotas {
+			if consumer != aclDefaultConsumer {
+				keys[quotaKey{consumer: consumer, method: method}] = true
+			} else if req.Consumer != "" {
+				keys[quotaKey{consumer: req.Consumer, method: method}] = true
+			}
+		}
+	}
+
+	now := time.Now()
+	ledger := m.quotas
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	for key := range ledger.counters {
+		keys[key] = true
+	}
+
+	report := &QuotaReport{}
+	for key := range keys {
+		consumer, method := key.consumer, key.method
+		if req.Consumer != "" && consumer != req.Consumer {
+			continue
+		}
+		quota, found := m.cfg.lookupQuota(consumer, method)
+		if !found {
+			continue
+		}
+		counter := &quotaCounter{}
+		if c, found := ledger.counters[key]; found {
+			counter = c
+		}
+		counter.roll(now)
+		report.Usage = append(report.Usage, &QuotaUsage{
+			Consumer:     consumer,
+			Method:       method,
+			Day:          counter.Day,
+			DailyUsed:    counter.DayUsed,
+			DailyLimit:   quota.Daily,
+			Month:        counter.Month,
+			MonthlyUsed:  counter.MonthUsed,
+			MonthlyLimit: quota.Monthly,
+		})
+	}
+	sort.Slice(report.Usage, func(i, j int) bool {
+		if report.Usage[i].Consumer != report.Usage[j].Consumer {
+			return report.Usage[i].Consumer < report.Usage[j].Consumer
+		}
+		return report.Usage[i].Method < report.Usage[j].Method
+	})
+	return report, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuotaCounterRoll(t *testing.T) {
+	counter := &quotaCounter{}
+	quota := Quota{Daily: 2, Monthly: 3}
+	day := time.Date(2020, 1, 31, 23, 0, 0, 0, time.UTC)
+
+	counter.roll(day)
+	counter.DayUsed, counter.MonthUsed = 2, 2
+	if !counter.exceeds(quota) {
+		t.Fatalf("expected daily quota to be exceeded")
+	}
+	counter.roll(day.Add(time.Hour))
+	if counter.DayUsed != 0 || counter.MonthUsed != 0 || counter.Month != "2020-02" {
+		t.Fatalf("expected counters to be reset on a new month, have %+v", counter)
+	}
+	counter.DayUsed, counter.MonthUsed = 1, 3
+	counter.roll(day.Add(25 * time.Hour))
+	if counter.DayUsed != 0 || counter.MonthUsed != 3 || !counter.exceeds(quota) {
+		t.Fatalf("expected only the daily counter to be reset, have %+v", counter)
+	}
+}
+
+func TestQuotaLedgerFlushRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "missing", "quota.json")
+
+	ledger, err := loadQuotaLedger(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ledger.counter(quotaKey{consumer: "biz_user", method: "*"}, time.Now()).DayUsed++
+	ledger.dirty = true
+	if err := ledger.flush(); err == nil {
+		t.Fatalf("expected an error writing into a missing dir")
+	}
+
+	// the usage is written by the next flush
+	if err := os.Mkdir(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("cant create dir: %v", err)
+	}
+	if err := ledger.flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := loadQuotaLedger(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.counters[quotaKey{consumer: "biz_user", method: "*"}].DayUsed != 1 {
+		t.Fatalf("expected the usage to be written, have %v", loaded.counters)
+	}
+}
+
+func TestQuotaLedgerKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "quota.json")
+
+	// a ledger of the old format keyed by "consumer method"
+	legacy := `{"biz user *": {"day": "2020-01-01", "day_used": 1, "month": "2020-01", "month_used": 1}}`
+	if err := ioutil.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatalf("cant write ledger: %v", err)
+	}
+	ledger, err := loadQuotaLedger(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if counter := ledger.counters[quotaKey{consumer: "biz user", method: "*"}]; counter == nil || counter.MonthUsed != 1 {
+		t.Fatalf("bad legacy ledger: %v", ledger.counters)
+	}
+
+	now := time.Now()
+	ledger.counter(quotaKey{consumer: "biz user", method: "/main.Biz/Add"}, now).DayUsed++
+	ledger.dirty = true
+	if err := ledger.flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ms := NewMsCtx()
+	ms.cfg = Config{Quotas: map[string]map[string]Quota{"biz user": {"/main.Biz/Add": {Daily: 5}}}}
+	ms.quotas, err = loadQuotaLedger(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report, err := ms.GetQuota(context.Background(), &QuotaRequest{Consumer: "biz user"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Usage) != 1 || report.Usage[0].Consumer != "biz user" || report.Usage[0].Method != "/main.Biz/Add" || report.Usage[0].DailyUsed != 1 {
+		t.Fatalf("bad usage of a consumer with a space: %v", report.Usage)
+	}
+}
+
+func TestQuotaRefundsRateLimit(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+		ConsumerRateLimits: map[string]RateLimit{"biz_user": {Rate: 0, Burst: 2}},
+		Quotas:             map[string]map[string]Quota{"biz_user": {"/main.Biz/Add": {Daily: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	if _, err := biz.Add(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := biz.Add(getConsumerCtx("biz_user"), &Nothing{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted on quota, got %v", err)
+	}
+	// the call rejected by the quota gave its token back
+	if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	acl := `{
+	"biz_user": ["/main.Biz/Check", "/main.Biz/Add"],
+	"stat":     ["/main.Admin/GetQuota"]
+}`
+	cfg := Config{
+		Quotas: map[string]map[string]Quota{
+			"biz_user": {"/main.Biz/Add": {Daily: 2}, "*": {Monthly: 3}},
+		},
+		QuotaFile:          filepath.Join(dir, "quota.json"),
+		QuotaFlushInterval: 10 * time.Millisecond,
+	}
+
+	ctx, finish := context.WithCancel(context.Background())
+	_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, acl, cfg)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+
+	conn := getGrpcConn(t)
+	biz := NewBizClient(conn)
+	for i := 0; i < 2; i++ {
+		if _, err := biz.Add(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+			t.Fatalf("[%d] unexpected error: %v", i, err)
+		}
+	}
+	if _, err := biz.Add(getConsumerCtx("biz_user"), &Nothing{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted on daily quota, got %v", err)
+	}
+	conn.Close()
+	finish()
+	wait(2)
+
+	// the usage survives a restart
+	ctx, finish = context.WithCancel(context.Background())
+	_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, acl, cfg)
+	if err != nil {
+		t.Fatalf("cant start server again: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn = getGrpcConn(t)
+	defer conn.Close()
+	biz = NewBizClient(conn)
+	adm := NewAdminClient(conn)
+	if _, err := biz.Add(getConsumerCtx("biz_user"), &Nothing{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted after restart, got %v", err)
+	}
+	if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted on monthly quota, got %v", err)
+	}
+
+	report, err := adm.GetQuota(getConsumerCtx("stat"), &QuotaRequest{Consumer: "biz_user"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Usage) != 2 {
+		t.Fatalf("expected 2 quotas, have %v", report.Usage)
+	}
+	all, add := report.Usage[0], report.Usage[1]
+	if all.Method != "*" || all.MonthlyUsed != 3 || all.MonthlyLimit != 3 {
+		t.Fatalf("bad usage of all methods: %v", all)
+	}
+	if add.Method != "/main.Biz/Add" || add.DailyUsed != 2 || add.DailyLimit != 2 || add.Day != time.Now().UTC().Format("2006-01-02") {
+		t.Fatalf("bad usage of Add: %v", add)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//RateLimit is a token bucket limit
+type RateLimit struct {
+	//Rate is how many calls per second are refilled
+	Rate float64
+	//Burst is how many calls may be made at once
+	Burst int
+}
+
+//tokenBucket is the state of a RateLimit for one key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//refill adds tokens for the time passed since the last refill
+func (b *tokenBucket) refill(limit RateLimit, now time.Time) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+}
+
+//wait returns how long to wait until the bucket has a token
+func (b *tokenBucket) wait(limit RateLimit) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if limit.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+//rateLimitKey is a key of a token bucket, the method is empty for a limit of all methods of a consumer
+type rateLimitKey struct {
+	consumer string
+	method   string
+}
+
+//rateLimiter keeps token buckets by consumer and by consumer and method
+type rateLimiter struct {
+	lock    *sync.Mutex
+	buckets map[rateLimitKey]*tokenBucket
+}
+
+//newRateLimiter helps to make a rateLimiter
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{lock: &sync.Mutex{}, buckets: make(map[rateLimitKey]*tokenBucket)}
+}
+
+//take takes a token from every bucket of keys or from none of them.
+//It returns how long to wait if some bucket is empty
+func (r *rateLimiter) take(keys []rateLimitKey, limits []RateLimit, now time.Time) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var retryAfter time.Duration
+	buckets := make([]*tokenBucket, len(keys))
+	for i, key := range keys {
+		bucket, found := r.buckets[key]
+		if !found {
+			bucket = &tokenBucket{tokens: float64(limits[i].Burst), last: now}
+			r.buckets[key] = bucket
+		}
+		bucket.refill(limits[i], now)
+		if wait := bucket.wait(limits[i]); wait > retryAfter {
+			retryAfter = wait
+		}
+		buckets[i] = bucket
+	}
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return 0
+}
+
+//refund gives back the tokens taken from the buckets of keys
+func (r *rateLimiter) refund(keys []rateLimitKey, limits []RateLimit) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, key := range keys {
+		if bucket, found := r.buckets[key]; found {
+			bucket.tokens = math.Min(float64(limits[i].Burst), bucket.tokens+1)
+		}
+	}
+}
+
+//rateLimitsFor returns limits of a consumer and of a consumer and method.
+//Limits of the "*" consumer are used for consumers without their own limits
+func (cfg Config) rateLimitsFor(consumer string, method string) (keys []rateLimitKey, limits []RateLimit) {
+	if limit, found := lookupRateLimit(cfg.ConsumerRateLimits, consumer); found {
+		keys = append(keys, rateLimitKey{consumer: consumer})
+		limits = append(limits, limit)
+	}
+	if limit, found := cfg.MethodRateLimits[consumer][method]; found {
+		keys = append(keys, rateLimitKey{consumer: consumer, method: method})
+		limits = append(limits, limit)
+	} else if limit, found := cfg.MethodRateLimits[aclDefaultConsumer][method]; found {
+		keys = append(keys, rateLimitKey{consumer: consumer, method: method})
+		limits = append(limits, limit)
+	}
+	return keys, limits
+}
+
+//lookupRateLimit returns a limit of a consumer or the "*" default
+func lookupRateLimit(limits map[string]RateLimit, consumer string) (RateLimit, bool) {
+	if limit, found := limits[consumer]; found {
+		return limit, true
+	}
+	limit, found := limits[aclDefaultConsumer]
+	return limit, found
+}
+
+//checkRateLimit returns codes.ResourceExhausted and the "retry-after" trailer
+//if the consumer exceeded its limits
+func (m *MsCtx) checkRateLimit(consumer string, method string) (metadata.MD, error) {
+	keys, limits := m.cfg.rateLimitsFor(consumer, method)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	retryAfter := m.limiter.take(keys, limits, time.Now())
+	if retryAfter == 0 {
+		return nil, nil
+	}
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if retryAfter == time.Duration(math.MaxInt64) {
+		seconds = math.MaxInt32
+	}
+	trailer := metadata.Pairs("retry-after", strconv.FormatInt(seconds, 10))
+	return trailer, status.Errorf(codes.ResourceExhausted, "rate limit exceeded for '%s', retry after %ds", consumer, seconds)
+}
+
+//refundRateLimit gives back the tokens taken by checkRateLimit for a call which has not been made
+func (m *MsCtx) refundRateLimit(consumer string, method string) {
+	keys, limits := m.cfg.rateLimitsFor(consumer, method)
+	if len(keys) != 0 {
+		m.limiter.refund(keys, limits)
+	}
+}
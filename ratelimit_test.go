@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := newRateLimiter()
+	limits := []RateLimit{{Rate: 10, Burst: 2}, {Rate: 1, Burst: 1}}
+	now := time.Now()
+
+	if wait := limiter.take([]rateLimitKey{{"a", ""}}, limits[:1], now); wait != 0 {
+		t.Fatalf("expected a token, have to wait %v", wait)
+	}
+	if wait := limiter.take([]rateLimitKey{{"a", ""}}, limits[:1], now); wait != 0 {
+		t.Fatalf("expected a token, have to wait %v", wait)
+	}
+	if wait := limiter.take([]rateLimitKey{{"a", ""}}, limits[:1], now); wait != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms, have %v", wait)
+	}
+	if wait := limiter.take([]rateLimitKey{{"a", ""}}, limits[:1], now.Add(100*time.Millisecond)); wait != 0 {
+		t.Fatalf("expected a refilled token, have to wait %v", wait)
+	}
+
+	// an empty bucket must not take tokens from the other one
+	if wait := limiter.take([]rateLimitKey{{"b", ""}, {"b", "m"}}, limits, now); wait != 0 {
+		t.Fatalf("expected a token, have to wait %v", wait)
+	}
+	if wait := limiter.take([]rateLimitKey{{"b", ""}, {"b", "m"}}, limits, now); wait != time.Second {
+		t.Fatalf("expected to wait 1s, have %v", wait)
+	}
+	if wait := limiter.take([]rateLimitKey{{"b", ""}}, limits[:1], now); wait != 0 {
+		t.Fatalf("expected a token, have to wait %v", wait)
+	}
+
+	// a consumer with a space in its name has its own bucket
+	if wait := limiter.take([]rateLimitKey{{"b m", ""}}, limits[1:], now); wait != 0 {
+		t.Fatalf("expected a token of another consumer, have to wait %v", wait)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+		ConsumerRateLimits: map[string]RateLimit{"*": {Rate: 0.1, Burst: 2}},
+		MethodRateLimits: map[string]map[string]RateLimit{
+			"biz_user": {"/main.Biz/Add": {Rate: 0.1, Burst: 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+
+	if _, err := biz.Add(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	trailer := metadata.MD{}
+	_, err = biz.Add(getConsumerCtx("biz_user"), &Nothing{}, grpc.Trailer(&trailer))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if retryAfter := trailer.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] != "10" {
+		t.Fatalf("expected retry-after 10, got %v", retryAfter)
+	}
+
+	if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	// limits are kept by consumer
+	if _, err := biz.Check(getConsumerCtx("biz_admin"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -5,58 +5,95 @@ package main
 
 import (
 	context "context" //this import from the code generation
-	"encoding/json"
 	"fmt"
 	"github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc" //this import from the code generation
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"io/ioutil"
 	"log"
 	"math"
 	"net"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 //MsCtx represents data which uses by this microservice
 type MsCtx struct {
-	Acl      map[string][]string
+	cfg      Config
+	acl      *atomic.Value //holds *aclPolicy
+	limiter  *rateLimiter
+	quotas   *quotaLedger
+	done     <-chan struct{} //closed when the microservice is stopped
 	Lock     *sync.Mutex
-	Loggers  map[int]chan *Event
+	lastID   int //the last id given to a logger or a statistics client
+	lastSeq  uint64
+	history  *eventHistory
+	events   *eventLog //nil if events are not written to disk
+	stats    *statStore
+	Loggers  map[int]*logSubscriber
 	StatData map[int]Stat
 }
 
 //Check is an implementation Check function of BizServer interface
 //Just a stub
-func (m MsCtx) Check(ctx context.Context, nothing *Nothing) (*Nothing, error) {
+func (m *MsCtx) Check(ctx context.Context, nothing *Nothing) (*Nothing, error) {
 	log.Println("Check")
 	return nothing, nil
 }
 
 //Add is an implementation Add function of BizServer interface
 //Just a stub
-func (m MsCtx) Add(ctx context.Context, nothing *Nothing) (*Nothing, error) {
+func (m *MsCtx) Add(ctx context.Context, nothing *Nothing) (*Nothing, error) {
 	log.Println("Add")
 	return nothing, nil
 }
 
 //Test is an implementation Test function of BizServer interface
 //Just a stub
-func (m MsCtx) Test(ctx context.Context, nothing *Nothing) (*Nothing, error) {
+func (m *MsCtx) Test(ctx context.Context, nothing *Nothing) (*Nothing, error) {
 	log.Println("Test")
 	return nothing, nil
 }
 
 //Logging is an implementation Logging function of AdminServer interface
-func (m *MsCtx) Logging(nothing *Nothing, server Admin_LoggingServer) error {
+func (m *MsCtx) Logging(req *LogRequest, server Admin_LoggingServer) error {
 	log.Println("Logging")
-	id, channel := m.addLogger()
-	log.Printf("Logger %d added \n", id)
+	filter, err := newLogFilter(req)
+	if err != nil {
+		return err
+	}
+	subscriber := newLogSubscriber(req, filter, m.cfg)
+	id, replay, err := m.addLogger(subscriber, req, server.Send)
+	if err != nil {
+		return err
+	}
+	log.Printf("Logger %d added, %d recent events to replay \n", id, len(replay.events))
+	defer m.deleteLogger(id)
+	if err := replay.send(subscriber.filter, server.Send); err != nil {
+		return err
+	}
 	for {
-		msg := <-channel
+		var msg *Event
+		select {
+		case msg = <-subscriber.events:
+		case <-subscriber.gone:
+			return status.Errorf(codes.ResourceExhausted, "logger %d is too slow, %d events dropped", id, subscriber.takeDropped())
+		case <-server.Context().Done():
+			log.Printf("Logger %d disconnected \n", id)
+			return status.FromContextError(server.Context().Err()).Err()
+		case <-m.done:
+			return status.Error(codes.Unavailable, "server is stopping")
+		}
+		if dropped := subscriber.takeDropped(); dropped != 0 {
+			withDropped := *msg
+			withDropped.Dropped = dropped
+			msg = &withDropped
+		}
 		log.Printf("sending to logger %v a message %#v \n", id, msg)
 		err := server.Send(msg)
 		if err != nil {
@@ -65,40 +102,131 @@ func (m *MsCtx) Logging(nothing *Nothing, server Admin_LoggingServer) error {
 	}
 }
 
-//logEvent sends notification to loggers
-func (m *MsCtx) logEvent(consumer string, method string, host string) {
-	log.Printf("logEvent consumer %s method %s \n", consumer, method)
+//logEvent sends notification to loggers and writes the event to the event log.
+//It never waits for a logger, a logger with a full buffer gets its overflow policy applied.
+//The event log is given the event after MsCtx.Lock is released, so a slow disk holds up only the calls logging events
+func (m *MsCtx) logEvent(event *Event) {
+	log.Printf("logEvent consumer %s method %s outcome %v \n", event.Consumer, event.Method, event.Outcome)
+	m.notify(event)
+	if m.events != nil {
+		if err := m.events.enqueue(event); err != nil {
+			log.Println("can't write event log:", err)
+		}
+	}
+}
+
+//notify gives an event its seq and sends it to loggers
+func (m *MsCtx) notify(event *Event) {
 	m.Lock.Lock()
 	defer m.Lock.Unlock()
-	for logger, c := range m.Loggers {
+	m.lastSeq++
+	event.Seq = m.lastSeq
+	event.Timestamp = time.Now().UnixNano()
+	m.history.add(event)
+	for logger, subscriber := range m.Loggers {
+		if !subscriber.filter.matches(event) {
+			continue
+		}
 		fmt.Printf("Notification to logger %v\n", logger)
-		c <- &Event{Timestamp: time.Now().UnixNano(), Consumer: consumer, Method: method, Host: host}
+		if !subscriber.offer(event) {
+			log.Printf("logger %v is disconnected for being too slow \n", logger)
+			delete(m.Loggers, logger)
+		}
 	}
 
 }
 
+//audit sends notification to loggers about an outcome of a call, err is the reason of a rejected or failed call
+func (m *MsCtx) audit(consumer string, method string, host string, outcome Outcome, err error) {
+	event := &Event{Consumer: consumer, Method: method, Host: host, Outcome: outcome}
+	if err != nil {
+		st := status.Convert(err)
+		event.Code = uint32(st.Code())
+		event.Reason = st.Message()
+	}
+	m.logEvent(event)
+}
+
+//complete sends notification to loggers about a call which has been handled,
+//with its duration, status and the bytes of its request and response messages
+func (m *MsCtx) complete(consumer string, method string, host string, duration time.Duration, err error, requestBytes uint64, responseBytes uint64) {
+	st := status.Convert(err)
+	m.logEvent(&Event{
+		Consumer:      consumer,
+		Method:        method,
+		Host:          host,
+		Outcome:       Outcome_COMPLETED,
+		Code:          uint32(st.Code()),
+		Reason:        st.Message(),
+		Duration:      int64(duration),
+		RequestBytes:  requestBytes,
+		ResponseBytes: responseBytes,
+	})
+}
+
 //Statistics is an implementation Statistics function of AdminServer interface
-func (m MsCtx) Statistics(interval *StatInterval, server Admin_StatisticsServer) error {
+func (m *MsCtx) Statistics(interval *StatInterval, server Admin_StatisticsServer) error {
 	log.Println("Statistics")
-	sec := interval.IntervalSeconds
-	ticker := time.NewTicker(time.Duration(sec) * time.Second)
-	clientId := m.addStatClient()
+	period, err := m.statPeriod(interval)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	clientId := m.addStatClient(interval)
+	defer m.deleteStatClient(clientId)
 	for {
-		<-ticker.C
-		stat := m.getStat(clientId)
+		select {
+		case <-ticker.C:
+		case <-server.Context().Done():
+			log.Printf("Statistics client %d disconnected \n", clientId)
+			return status.FromContextError(server.Context().Err()).Err()
+		case <-m.done:
+			return status.Error(codes.Unavailable, "server is stopping")
+		}
+		stat := m.takeStat(clientId, interval)
 		err := server.Send(&stat)
-		m.clearStat(clientId)
 		if err != nil {
-			m.deleteStatClient(clientId)
 			return err
 		}
 	}
 }
 
-func (m *MsCtx) addStatClient() int {
+//minStatInterval is the shortest interval a statistics client may ask for
+const minStatInterval = 10 * time.Millisecond
+
+//statPeriod validates a statistics request and returns its interval, interval_ms wins over interval_seconds
+func (m *MsCtx) statPeriod(interval *StatInterval) (time.Duration, error) {
+	period := time.Duration(interval.IntervalSeconds) * time.Second
+	if interval.IntervalMs != 0 {
+		period = time.Duration(interval.IntervalMs) * time.Millisecond
+	}
+	if period <= 0 {
+		return 0, status.Error(codes.InvalidArgument, "interval is not set")
+	}
+	if period < minStatInterval {
+		return 0, status.Errorf(codes.InvalidArgument, "interval %v is shorter than %v", period, minStatInterval)
+	}
+	if _, found := StatMode_name[int32(interval.Mode)]; !found {
+		return 0, status.Errorf(codes.InvalidArgument, "unknown mode %d", interval.Mode)
+	}
+	if window := time.Duration(interval.WindowSeconds) * time.Second; window > m.stats.window() {
+		return 0, status.Errorf(codes.InvalidArgument, "window %v is longer than %v", window, m.stats.window())
+	}
+	return period, nil
+}
+
+//addStatClient adds a statistics client to MsCtx, the client gets counts of calls made after that.
+//A client of the totals only needs no snapshot to take the difference with
+func (m *MsCtx) addStatClient(interval *StatInterval) int {
+	snapshot := &Stat{}
+	if interval.Mode != StatMode_CUMULATIVE {
+		snapshot = m.stats.snapshotFor(interval)
+	}
 	m.Lock.Lock()
-	number := len(m.StatData)
-	m.StatData[number] = Stat{ByConsumer: make(map[string]uint64), ByMethod: make(map[string]uint64)}
+	m.lastID++
+	number := m.lastID
+	m.StatData[number] = *snapshot
 	m.Lock.Unlock()
 	return number
 }
@@ -109,91 +237,316 @@ func (m *MsCtx) deleteStatClient(client int) {
 	m.Lock.Unlock()
 }
 
-func (m *MsCtx) clearStat(number int) {
-	m.Lock.Lock()
-	m.StatData[number] = Stat{ByConsumer: make(map[string]uint64), ByMethod: make(map[string]uint64)}
-	m.Lock.Unlock()
+//takeStat returns the counts of calls made since the last time a client took them.
+//StatData keeps the totals as the client saw them last time, the counts are the difference with the totals now.
+//Only the breakdowns the client asked for are copied from the totals and compared.
+//Totals since the start of the microservice replace the counts or come along with them as the client asked,
+//so do the counts of the recent window
+func (m *MsCtx) takeStat(client int, interval *StatInterval) Stat {
+	now := time.Now()
+	totals := m.stats.snapshotFor(interval)
+	var result Stat
+	if interval.Mode == StatMode_CUMULATIVE {
+		result = *totals
+	} else {
+		m.Lock.Lock()
+		last := m.StatData[client]
+		m.StatData[client] = *totals
+		m.Lock.Unlock()
+		result = diffStat(totals, &last)
+	}
+	if interval.Mode == StatMode_BOTH {
+		//the snapshot is a copy already and its counts are not changed any more, so it is sent as it is
+		result.Cumulative = totals
+		selectStat(result.Cumulative, interval, now)
+	}
+	selectStat(&result, interval, now)
+	if interval.WindowSeconds != 0 {
+		result.Window = m.stats.recent(time.Duration(interval.WindowSeconds)*time.Second, now)
+		selectStat(result.Window, interval, now)
+	}
+	return result
 }
 
-func (m *MsCtx) getStat(client int) Stat {
-	m.Lock.Lock()
-	defer m.Lock.Unlock()
-	s := m.StatData[client]
-	s.Timestamp = time.Now().UnixNano()
-	return s
+//addUsageStat counts a call of a consumer from a host, host is the address of the peer
+func (m *MsCtx) addUsageStat(consumer string, method string, host string) {
+	m.stats.addUsage(consumer, method, hostOf(host), time.Now())
 }
 
-func (m *MsCtx) addUsageStat(consumer string, method string) {
-	m.Lock.Lock()
-	for _, stat := range m.StatData {
-		stat.ByMethod[method]++
-		stat.ByConsumer[consumer]++
-	}
-	m.Lock.Unlock()
+//addCodeStat counts the status of a finished call, err is nil for a successful call
+func (m *MsCtx) addCodeStat(consumer string, method string, host string, err error) {
+	m.stats.addCode(consumer, method, hostOf(host), status.Code(err).String(), time.Now())
 }
 
 //NewMsCtx helps to make a MsCtx
 func NewMsCtx() *MsCtx {
 	result := &MsCtx{}
+	result.acl = &atomic.Value{}
 	result.Lock = &sync.Mutex{}
-	result.Loggers = make(map[int]chan *Event)
+	result.limiter = newRateLimiter()
+	result.quotas = newQuotaLedger()
+	result.history = newEventHistory(0)
+	result.stats = newStatStore(0, Config{}.latencyBounds())
+	result.Loggers = make(map[int]*logSubscriber)
 	result.StatData = make(map[int]Stat)
 	return result
 }
 
-//addLogger adds a logger to MsCtx and returns a number of the added logger and a created channel of the logger
-func (m *MsCtx) addLogger() (int, chan *Event) {
-	m.Lock.Lock()
-	defer m.Lock.Unlock()
-	count := len(m.Loggers)
-	m.Loggers[count] = make(chan *Event)
-	return count, m.Loggers[count]
+//logReplay is what a new logger gets before live events,
+//either recent events from memory or events after a cursor from the event log
+type logReplay struct {
+	events []*Event
+	log    *eventLog //nil if the events are replayed from memory
+	after  uint64
+	upTo   uint64
 }
 
-//isConsumerAllowed returns true if a consumer and a method are allowed
-func (m MsCtx) isConsumerAllowed(consumer string, checkingMethod string) bool {
-	methods, found := m.Acl[consumer]
-	if !found {
-		return false
-	}
-	for _, method := range methods {
-		if strings.HasSuffix(method, "*") {
-			return true
+//send sends the events to replay, the event log is read without holding MsCtx.Lock
+//once the events up to the live ones are written
+func (r *logReplay) send(filter *logFilter, send func(*Event) error) error {
+	for _, event := range r.events {
+		if err := send(event); err != nil {
+			return err
 		}
-		if method == checkingMethod {
-			return true
+	}
+	if r.log == nil {
+		return nil
+	}
+	return replayEventLog(r.log.segmentsUpTo(r.upTo), r.after, r.upTo, filter, send)
+}
+
+//addLogger adds a logger to MsCtx and returns a unique number of the added logger
+//with the events it asked to replay. The logger gets every event after the replayed ones.
+//A logger resuming after a cursor gets events from the event log if there is one, and from memory otherwise.
+//Events of the event log are sent to the logger before it is added until the events left fit into its buffer,
+//so live events don't overflow the buffer while a long replay is sent
+func (m *MsCtx) addLogger(subscriber *logSubscriber, req *LogRequest, send func(*Event) error) (int, *logReplay, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+	replay := &logReplay{}
+	switch {
+	case req.ResumeAfter != 0 && m.events != nil:
+		replay.log = m.events
+		replay.after = req.ResumeAfter
+		for m.lastSeq > replay.after+uint64(cap(subscriber.events)) {
+			replay.upTo = m.lastSeq
+			m.Lock.Unlock()
+			err := replay.send(subscriber.filter, send)
+			m.Lock.Lock()
+			if err != nil {
+				return 0, nil, err
+			}
+			replay.after = replay.upTo
 		}
+		replay.upTo = m.lastSeq
+	case req.ReplayLast != 0 || req.ReplaySince != 0 || req.ResumeAfter != 0:
+		replay.events = m.history.replay(subscriber.filter, int(req.ReplayLast), req.ReplaySince, req.ResumeAfter)
 	}
-	return false
+	m.lastID++
+	m.Loggers[m.lastID] = subscriber
+	return m.lastID, replay, nil
+}
+
+//deleteLogger removes a logger from MsCtx
+func (m *MsCtx) deleteLogger(id int) {
+	m.Lock.Lock()
+	delete(m.Loggers, id)
+	m.Lock.Unlock()
 }
 
+//isConsumerAllowed returns true if a consumer and a method are allowed
+func (m *MsCtx) isConsumerAllowed(consumer string, checkingMethod string) bool {
+	return m.getAcl().isAllowed(consumer, checkingMethod)
+}
+
+//Config holds optional settings of the microservice
+type Config struct {
+	//AclFile is a path to a watched ACL file. If it is set, the ACL is read from the file
+	//instead of the data argument and is reloaded every time the file changes
+	AclFile string
+	//AclPollInterval is how often AclFile is checked for changes, one second by default
+	AclPollInterval time.Duration
+	//AuthKeys are HMAC keys by key id. If they are set, a consumer is taken from
+	//a signed bearer token instead of the "consumer" metadata, see IssueToken
+	AuthKeys map[string][]byte
+	//TLSCertFile and TLSKeyFile make the server serve TLS
+	TLSCertFile string
+	TLSKeyFile  string
+	//TLSClientCAFile is a CA bundle which client certificates are verified against.
+	//If it is set, a client certificate is required and a consumer is taken from it.
+	//It needs TLSCertFile and can't be used together with AuthKeys
+	TLSClientCAFile string
+	//TLSIdentity is the part of a client certificate which holds a consumer name:
+	//"cn" for the subject common name (the default) or "san" for the first DNS, URI or email SAN
+	TLSIdentity string
+	//LogBufferSize is how many events a logger may lag behind unless it asks for another size, 64 by default
+	LogBufferSize int
+	//LogOverflowPolicy is what happens when a logger lags behind too much unless it asks for another policy,
+	//the oldest buffered event is dropped by default
+	LogOverflowPolicy OverflowPolicy
+	//EventHistorySize is how many recent events are kept for replay to new loggers, 1024 by default
+	EventHistorySize int
+	//EventLogDir is where every event is written to, events are kept in memory only if it is empty
+	EventLogDir string
+	//EventLogSegmentSize is the size in bytes after which a new file of the event log is started, 4MB by default
+	EventLogSegmentSize int64
+	//EventLogRetentionSize is how many bytes of the event log are kept, the oldest files are removed first,
+	//there is no limit if it is zero
+	EventLogRetentionSize int64
+	//EventLogRetentionAge is how long files of the event log are kept after they were written,
+	//there is no limit if it is zero
+	EventLogRetentionAge time.Duration
+	//LatencyBuckets are the upper bounds of buckets of latency histograms in Stat,
+	//from one millisecond to ten seconds by default
+	LatencyBuckets []time.Duration
+	//StatWindow is how long counts of recent calls are kept for windowed statistics, five minutes by default
+	StatWindow time.Duration
+	//MetricsAddr is where the counters are served over HTTP in the Prometheus text format,
+	//they are not served if it is empty
+	MetricsAddr string
+	//ConsumerRateLimits limit calls of a consumer, the "*" consumer is the default limit
+	ConsumerRateLimits map[string]RateLimit
+	//MethodRateLimits limit calls of a method by a consumer, the "*" consumer is the default limit
+	MethodRateLimits map[string]map[string]RateLimit
+	//Quotas limit calls of a consumer per day and month by method,
+	//the "*" method counts all methods together and the "*" consumer is the default quota
+	Quotas map[string]map[string]Quota
+	//QuotaFile keeps quota usage between restarts, usage is kept in memory only if it is empty
+	QuotaFile string
+	//QuotaFlushInterval is how often quota usage is written to QuotaFile, one second by default
+	QuotaFlushInterval time.Duration
+	//StatFile keeps the totals of statistics between restarts, they are kept in memory only if it is empty
+	StatFile string
+	//StatFlushInterval is how often the totals are written to StatFile, one second by default
+	StatFlushInterval time.Duration
+}
+
+//StartMyMicroservice starts the microservice with the default config
 func StartMyMicroservice(ctx context.Context, addr string, data string) error {
+	_, err := StartMyMicroserviceWithConfig(ctx, addr, data, Config{})
+	return err
+}
 
+//StartMyMicroserviceWithConfig starts the microservice and returns it
+//so that the caller can manage it while it is running
+func StartMyMicroserviceWithConfig(ctx context.Context, addr string, data string, cfg Config) (*MsCtx, error) {
+
+	if err := cfg.checkAuthConfig(); err != nil {
+		return nil, err
+	}
 	msCtx := NewMsCtx()
-	err := json.Unmarshal([]byte(data), &msCtx.Acl)
+	msCtx.cfg = cfg
+	msCtx.done = ctx.Done()
+	msCtx.history = newEventHistory(cfg.EventHistorySize)
+	msCtx.stats = newStatStore(cfg.StatWindow, cfg.latencyBounds())
+	msCtx.stats.consumerKey = msCtx.statConsumer
+	if cfg.AclFile != "" {
+		fileData, err := ioutil.ReadFile(cfg.AclFile)
+		if err != nil {
+			return nil, err
+		}
+		data = string(fileData)
+	}
+	err := msCtx.ReloadAcl(data)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	msCtx.quotas, err = loadQuotaLedger(cfg.QuotaFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.StatFile != "" {
+		if err := msCtx.stats.load(cfg.StatFile); err != nil {
+			return nil, err
+		}
+	}
+
+	serverOptions := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.StreamInterceptor(streamInterceptor),
+	}
+	if cfg.TLSCertFile != "" {
+		tlsConfig, err := newServerTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	if cfg.EventLogDir != "" {
+		msCtx.events, err = openEventLog(cfg)
+		if err != nil {
+			return nil, err
+		}
+		msCtx.lastSeq = msCtx.events.lastSeq
 	}
 
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Println("can't listen a port:", addr, err)
-		return err
+		if msCtx.events != nil {
+			msCtx.events.close()
+		}
+		return nil, err
+	}
+	var metricsLis net.Listener
+	if cfg.MetricsAddr != "" {
+		metricsLis, err = net.Listen("tcp", cfg.MetricsAddr)
+		if err != nil {
+			log.Println("can't listen a metrics port:", cfg.MetricsAddr, err)
+			lis.Close()
+			if msCtx.events != nil {
+				msCtx.events.close()
+			}
+			return nil, err
+		}
 	}
 
-	server := grpc.NewServer(
-		grpc.UnaryInterceptor(unaryInterceptor),
-		grpc.StreamInterceptor(streamInterceptor),
-	)
+	server := grpc.NewServer(serverOptions...)
 
 	RegisterAdminServer(server, msCtx)
 	RegisterBizServer(server, msCtx)
 
+	if cfg.AclFile != "" {
+		interval := cfg.AclPollInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		go msCtx.WatchAclFile(ctx, cfg.AclFile, interval)
+	}
+	if cfg.QuotaFile != "" {
+		interval := cfg.QuotaFlushInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		go flushEvery(ctx, interval, "quota ledger "+cfg.QuotaFile, msCtx.quotas.flush)
+	}
+	if cfg.StatFile != "" {
+		interval := cfg.StatFlushInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		go flushEvery(ctx, interval, "statistics "+cfg.StatFile, msCtx.stats.flush)
+	}
+	if msCtx.events != nil && cfg.EventLogRetentionAge > 0 {
+		go msCtx.expireEvents(ctx, time.Minute)
+	}
+	if metricsLis != nil {
+		go msCtx.serveMetrics(ctx, metricsLis)
+	}
+
 	go func() {
 		select {
 		case <-ctx.Done():
 			log.Println("closing server")
 			server.GracefulStop()
+			if err := msCtx.quotas.flush(); err != nil {
+				log.Println("can't flush quota ledger:", cfg.QuotaFile, err)
+			}
+			if err := msCtx.stats.flush(); err != nil {
+				log.Println("can't flush statistics:", cfg.StatFile, err)
+			}
+			msCtx.closeEventLog()
 		}
 	}()
 
@@ -202,7 +555,7 @@ func StartMyMicroservice(ctx context.Context, addr string, data string) error {
 		server.Serve(lis)
 	}()
 
-	return err
+	return msCtx, nil
 }
 
 //unaryInterceptor presents an unary interceptor for grpc
@@ -214,29 +567,39 @@ func unaryInterceptor(
 ) (interface{}, error) {
 	start := time.Now()
 
-	if _, err := checkRights(ctx, info.Server, info.FullMethod); err != nil {
-		return nil, err
-	}
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		log.Println("metadata.FromIncomingContext(ctx) is !ok")
-		return nil, status.Error(codes.Internal, "internal error")
-	}
-	consumer, err := getConsumer(md)
+	consumer, err := checkRights(ctx, info.Server, info.FullMethod)
 	if err != nil {
 		return nil, err
 	}
 
-	p, ok := peer.FromContext(ctx)
-	if !ok {
-		log.Println("peer.FromContext(ctx) is !ok")
-		return nil, status.Error(codes.Internal, "internal error")
+	host, err := getHost(ctx)
+	if err != nil {
+		return nil, err
 	}
-	host := p.Addr.String()
 	msCtx := info.Server.(*MsCtx)
-	msCtx.logEvent(consumer, info.FullMethod, host)
-	msCtx.addUsageStat(consumer, info.FullMethod)
+	msCtx.addUsageStat(consumer, info.FullMethod, host)
+	if trailer, err := msCtx.checkRateLimit(consumer, info.FullMethod); err != nil {
+		msCtx.addCodeStat(consumer, info.FullMethod, host, err)
+		msCtx.audit(consumer, info.FullMethod, host, Outcome_LIMITED, err)
+		grpc.SetTrailer(ctx, trailer)
+		return nil, err
+	}
+	if err := msCtx.chargeQuota(consumer, info.FullMethod); err != nil {
+		//the call is not made, so it does not use up the rate limit
+		msCtx.refundRateLimit(consumer, info.FullMethod)
+		msCtx.addCodeStat(consumer, info.FullMethod, host, err)
+		msCtx.audit(consumer, info.FullMethod, host, Outcome_LIMITED, err)
+		return nil, err
+	}
+	msCtx.audit(consumer, info.FullMethod, host, Outcome_ALLOWED, nil)
 	reply, err := handler(ctx, req)
+	if err != nil {
+		msCtx.audit(consumer, info.FullMethod, host, Outcome_FAILED, err)
+	}
+	duration := time.Since(start)
+	msCtx.addLatencyStat(consumer, info.FullMethod, host, duration)
+	msCtx.addCodeStat(consumer, info.FullMethod, host, err)
+	msCtx.complete(consumer, info.FullMethod, host, duration, err, messageSize(req), messageSize(reply))
 
 	log.Printf(`--
 	after incoming call=%v
@@ -252,36 +615,103 @@ func unaryInterceptor(
 func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	start := time.Now()
 
-	if _, err := checkRights(ss.Context(), srv, info.FullMethod); err != nil {
-		return err
-	}
-
-	md, _ := metadata.FromIncomingContext(ss.Context())
-	consumer, err := getConsumer(md)
+	consumer, err := checkRights(ss.Context(), srv, info.FullMethod)
 	if err != nil {
 		return err
 	}
 	//host is "127.0.0.1:"
-	p, ok := peer.FromContext(ss.Context())
-	if !ok {
-		log.Println("peer.FromContext(ctx) is !ok")
-		return status.Error(codes.Internal, "internal error")
+	host, err := getHost(ss.Context())
+	if err != nil {
+		return err
 	}
-	host := p.Addr.String()
 	msCtx := srv.(*MsCtx)
-	msCtx.logEvent(consumer, info.FullMethod, host)
-	msCtx.addUsageStat(consumer, info.FullMethod)
+	msCtx.addUsageStat(consumer, info.FullMethod, host)
+	if trailer, err := msCtx.checkRateLimit(consumer, info.FullMethod); err != nil {
+		msCtx.addCodeStat(consumer, info.FullMethod, host, err)
+		msCtx.audit(consumer, info.FullMethod, host, Outcome_LIMITED, err)
+		ss.SetTrailer(trailer)
+		return err
+	}
+	if err := msCtx.chargeQuota(consumer, info.FullMethod); err != nil {
+		//the call is not made, so it does not use up the rate limit
+		msCtx.refundRateLimit(consumer, info.FullMethod)
+		msCtx.addCodeStat(consumer, info.FullMethod, host, err)
+		msCtx.audit(consumer, info.FullMethod, host, Outcome_LIMITED, err)
+		return err
+	}
+	msCtx.audit(consumer, info.FullMethod, host, Outcome_ALLOWED, nil)
 
-	err = handler(srv, ss)
+	sized := &sizedServerStream{ServerStream: ss}
+	err = handler(srv, sized)
+	if err != nil {
+		msCtx.audit(consumer, info.FullMethod, host, Outcome_FAILED, err)
+	}
+	duration := time.Since(start)
+	msCtx.addLatencyStat(consumer, info.FullMethod, host, duration)
+	msCtx.addCodeStat(consumer, info.FullMethod, host, err)
+	msCtx.complete(consumer, info.FullMethod, host, duration, err,
+		atomic.LoadUint64(&sized.received), atomic.LoadUint64(&sized.sent))
 	log.Printf(`--
 	after incoming call=%v
-	req=%#v
 	time=%v
 	err=%v
-`, info.FullMethod, srv, time.Since(start), err)
+`, info.FullMethod, time.Since(start), err)
+	return err
+}
+
+//sizedServerStream counts the bytes of messages received and sent by a stream
+type sizedServerStream struct {
+	grpc.ServerStream
+	received uint64 //atomic
+	sent     uint64 //atomic
+}
+
+//SendMsg sends a message and counts its size
+func (s *sizedServerStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		atomic.AddUint64(&s.sent, messageSize(msg))
+	}
+	return err
+}
+
+//RecvMsg receives a message and counts its size
+func (s *sizedServerStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		atomic.AddUint64(&s.received, messageSize(msg))
+	}
 	return err
 }
 
+//messageSize returns the size of a message in the wire format, zero if it is not a protobuf message
+func messageSize(msg interface{}) uint64 {
+	message, ok := msg.(proto.Message)
+	if !ok || message == nil {
+		return 0
+	}
+	return uint64(proto.Size(message))
+}
+
+//hostOf returns the host part of a "host:port" address or the whole address if it has no port
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+//getHost returns an address of a peer of a call
+func getHost(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		log.Println("peer.FromContext(ctx) is !ok")
+		return "", status.Error(codes.Internal, "internal error")
+	}
+	return p.Addr.String(), nil
+}
+
 //getConsumer returns a consumer from metadata.MD or error
 func getConsumer(md metadata.MD) (string, error) {
 
@@ -299,38 +729,46 @@ func getConsumer(md metadata.MD) (string, error) {
 
 }
 
-func checkRights(ctx context.Context, srv interface{}, method string) (bool, error) {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		log.Println("metadata.FromIncomingContext(ctx) has !ok")
-		return false, status.Error(codes.Internal, "internal error")
-	}
-	consumer, ok := md["consumer"]
-	log.Printf(`--checkRights 
-	consumer=%v
-	ok=%#v
-	md=%v
-`, consumer, ok, md)
-
+//checkRights authenticates a consumer and checks its rights for a method.
+//It returns the consumer if the call is allowed, a rejected call is reported to loggers and statistics
+func checkRights(ctx context.Context, srv interface{}, method string) (string, error) {
+	msCtx, ok := srv.(*MsCtx)
 	if !ok {
-		return false, status.Error(codes.Unauthenticated, "no consumer from you")
+		log.Println("srv.(*MsCtx) has !ok")
+		return "", status.Error(codes.Internal, "internal error")
 	}
-
-	if len(consumer) == 0 {
-		return false, status.Error(codes.Unauthenticated, "no consumer from you")
+	host, err := getHost(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	msCtx, ok := srv.(*MsCtx)
-	if !ok {
-		log.Println("srv.(*MsCtx) has !ok")
-		return false, status.Error(codes.Internal, "internal error")
+	consumer, err := msCtx.authenticate(ctx)
+	log.Printf(`--checkRights 
+	consumer=%v
+	err=%v
+`, consumer, err)
+	if err != nil {
+		msCtx.addUsageStat("", method, host)
+		msCtx.addCodeStat("", method, host, err)
+		//the claimed consumer is not trusted, it is only named in the reason to help to find out who is rejected
+		reason := err
+		md, _ := metadata.FromIncomingContext(ctx)
+		if claimed, _ := getConsumer(md); claimed != "" {
+			reason = status.Errorf(status.Code(err), "%s, claimed consumer '%s'", status.Convert(err).Message(), claimed)
+		}
+		msCtx.audit("", method, host, Outcome_UNAUTHENTICATED, reason)
+		return "", err
 	}
 
-	hasRight := msCtx.isConsumerAllowed(consumer[0], method)
+	hasRight := msCtx.isConsumerAllowed(consumer, method)
 	if !hasRight {
-		return false, status.Error(codes.Unauthenticated, fmt.Sprintf("no rights for '%s'", consumer[0]))
+		err := status.Error(codes.Unauthenticated, fmt.Sprintf("no rights for '%s'", consumer))
+		msCtx.addUsageStat(consumer, method, host)
+		msCtx.addCodeStat(consumer, method, host, err)
+		msCtx.audit(consumer, method, host, Outcome_DENIED, err)
+		return "", err
 	}
-	return true, nil
+	return consumer, nil
 }
 
 /*Below is generated code*/
@@ -348,11 +786,127 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type Outcome int32
+
+const (
+	Outcome_ALLOWED         Outcome = 0
+	Outcome_DENIED          Outcome = 1
+	Outcome_UNAUTHENTICATED Outcome = 2
+	Outcome_FAILED          Outcome = 3
+	Outcome_LIMITED         Outcome = 4
+	Outcome_COMPLETED       Outcome = 5
+)
+
+var Outcome_name = map[int32]string{
+	0: "ALLOWED",
+	1: "DENIED",
+	2: "UNAUTHENTICATED",
+	3: "FAILED",
+	4: "LIMITED",
+	5: "COMPLETED",
+}
+var Outcome_value = map[string]int32{
+	"ALLOWED":         0,
+	"DENIED":          1,
+	"UNAUTHENTICATED": 2,
+	"FAILED":          3,
+	"LIMITED":         4,
+	"COMPLETED":       5,
+}
+
+func (x Outcome) String() string {
+	return proto.EnumName(Outcome_name, int32(x))
+}
+func (Outcome) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type OverflowPolicy int32
+
+const (
+	OverflowPolicy_POLICY_DEFAULT OverflowPolicy = 0
+	OverflowPolicy_DROP_OLDEST    OverflowPolicy = 1
+	OverflowPolicy_DROP_NEWEST    OverflowPolicy = 2
+	OverflowPolicy_DISCONNECT     OverflowPolicy = 3
+)
+
+var OverflowPolicy_name = map[int32]string{
+	0: "POLICY_DEFAULT",
+	1: "DROP_OLDEST",
+	2: "DROP_NEWEST",
+	3: "DISCONNECT",
+}
+var OverflowPolicy_value = map[string]int32{
+	"POLICY_DEFAULT": 0,
+	"DROP_OLDEST":    1,
+	"DROP_NEWEST":    2,
+	"DISCONNECT":     3,
+}
+
+func (x OverflowPolicy) String() string {
+	return proto.EnumName(OverflowPolicy_name, int32(x))
+}
+func (OverflowPolicy) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type StatMode int32
+
+const (
+	StatMode_DELTA      StatMode = 0
+	StatMode_CUMULATIVE StatMode = 1
+	StatMode_BOTH       StatMode = 2
+)
+
+var StatMode_name = map[int32]string{
+	0: "DELTA",
+	1: "CUMULATIVE",
+	2: "BOTH",
+}
+var StatMode_value = map[string]int32{
+	"DELTA":      0,
+	"CUMULATIVE": 1,
+	"BOTH":       2,
+}
+
+func (x StatMode) String() string {
+	return proto.EnumName(StatMode_name, int32(x))
+}
+func (StatMode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type TopOrder int32
+
+const (
+	TopOrder_CALLS   TopOrder = 0
+	TopOrder_ERRORS  TopOrder = 1
+	TopOrder_LATENCY TopOrder = 2
+)
+
+var TopOrder_name = map[int32]string{
+	0: "CALLS",
+	1: "ERRORS",
+	2: "LATENCY",
+}
+var TopOrder_value = map[string]int32{
+	"CALLS":   0,
+	"ERRORS":  1,
+	"LATENCY": 2,
+}
+
+func (x TopOrder) String() string {
+	return proto.EnumName(TopOrder_name, int32(x))
+}
+func (TopOrder) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
 type Event struct {
-	Timestamp int64  `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
-	Consumer  string `protobuf:"bytes,2,opt,name=consumer" json:"consumer,omitempty"`
-	Method    string `protobuf:"bytes,3,opt,name=method" json:"method,omitempty"`
-	Host      string `protobuf:"bytes,4,opt,name=host" json:"host,omitempty"`
+	Timestamp     int64   `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Consumer      string  `protobuf:"bytes,2,opt,name=consumer" json:"consumer,omitempty"`
+	Method        string  `protobuf:"bytes,3,opt,name=method" json:"method,omitempty"`
+	Host          string  `protobuf:"bytes,4,opt,name=host" json:"host,omitempty"`
+	Outcome       Outcome `protobuf:"varint,5,opt,name=outcome,enum=main.Outcome" json:"outcome,omitempty"`
+	Code          uint32  `protobuf:"varint,6,opt,name=code" json:"code,omitempty"`
+	Reason        string  `protobuf:"bytes,7,opt,name=reason" json:"reason,omitempty"`
+	Dropped       uint64  `protobuf:"varint,8,opt,name=dropped" json:"dropped,omitempty"`
+	Seq           uint64  `protobuf:"varint,9,opt,name=seq" json:"seq,omitempty"`
+	Duration      int64   `protobuf:"varint,10,opt,name=duration" json:"duration,omitempty"`
+	RequestBytes  uint64  `protobuf:"varint,11,opt,name=request_bytes,json=requestBytes" json:"request_bytes,omitempty"`
+	ResponseBytes uint64  `protobuf:"varint,12,opt,name=response_bytes,json=responseBytes" json:"response_bytes,omitempty"`
 }
 
 func (m *Event) Reset()                    { *m = Event{} }
@@ -388,10 +942,73 @@ func (m *Event) GetHost() string {
 	return ""
 }
 
+func (m *Event) GetOutcome() Outcome {
+	if m != nil {
+		return m.Outcome
+	}
+	return Outcome_ALLOWED
+}
+
+func (m *Event) GetCode() uint32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *Event) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *Event) GetDropped() uint64 {
+	if m != nil {
+		return m.Dropped
+	}
+	return 0
+}
+
+func (m *Event) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Event) GetDuration() int64 {
+	if m != nil {
+		return m.Duration
+	}
+	return 0
+}
+
+func (m *Event) GetRequestBytes() uint64 {
+	if m != nil {
+		return m.RequestBytes
+	}
+	return 0
+}
+
+func (m *Event) GetResponseBytes() uint64 {
+	if m != nil {
+		return m.ResponseBytes
+	}
+	return 0
+}
+
 type Stat struct {
-	Timestamp  int64             `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
-	ByMethod   map[string]uint64 `protobuf:"bytes,2,rep,name=by_method,json=byMethod" json:"by_method,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
-	ByConsumer map[string]uint64 `protobuf:"bytes,3,rep,name=by_consumer,json=byConsumer" json:"by_consumer,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Timestamp        int64                        `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	ByMethod         map[string]uint64            `protobuf:"bytes,2,rep,name=by_method,json=byMethod" json:"by_method,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	ByConsumer       map[string]uint64            `protobuf:"bytes,3,rep,name=by_consumer,json=byConsumer" json:"by_consumer,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	LatencyByMethod  map[string]*LatencyHistogram `protobuf:"bytes,4,rep,name=latency_by_method,json=latencyByMethod" json:"latency_by_method,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ByCode           map[string]uint64            `protobuf:"bytes,5,rep,name=by_code,json=byCode" json:"by_code,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	ByMethodCode     map[string]*CodeCounts       `protobuf:"bytes,6,rep,name=by_method_code,json=byMethodCode" json:"by_method_code,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ByHost           map[string]uint64            `protobuf:"bytes,7,rep,name=by_host,json=byHost" json:"by_host,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	ByConsumerMethod map[string]*MethodCounts     `protobuf:"bytes,8,rep,name=by_consumer_method,json=byConsumerMethod" json:"by_consumer_method,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Cumulative       *Stat                        `protobuf:"bytes,9,opt,name=cumulative" json:"cumulative,omitempty"`
+	Window           *Stat                        `protobuf:"bytes,10,opt,name=window" json:"window,omitempty"`
 }
 
 func (x *Stat) Reset()                    { *x = Stat{} }
@@ -420,12 +1037,66 @@ func (x *Stat) GetByConsumer() map[string]uint64 {
 	return nil
 }
 
-type StatInterval struct {
-	IntervalSeconds uint64 `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds" json:"interval_seconds,omitempty"`
+func (x *Stat) GetLatencyByMethod() map[string]*LatencyHistogram {
+	if x != nil {
+		return x.LatencyByMethod
+	}
+	return nil
 }
 
-func (x *StatInterval) Reset()                    { *x = StatInterval{} }
-func (x *StatInterval) String() string            { return proto.CompactTextString(x) }
+func (x *Stat) GetByCode() map[string]uint64 {
+	if x != nil {
+		return x.ByCode
+	}
+	return nil
+}
+
+func (x *Stat) GetByMethodCode() map[string]*CodeCounts {
+	if x != nil {
+		return x.ByMethodCode
+	}
+	return nil
+}
+
+func (x *Stat) GetByHost() map[string]uint64 {
+	if x != nil {
+		return x.ByHost
+	}
+	return nil
+}
+
+func (x *Stat) GetByConsumerMethod() map[string]*MethodCounts {
+	if x != nil {
+		return x.ByConsumerMethod
+	}
+	return nil
+}
+
+func (x *Stat) GetCumulative() *Stat {
+	if x != nil {
+		return x.Cumulative
+	}
+	return nil
+}
+
+func (x *Stat) GetWindow() *Stat {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+type StatInterval struct {
+	IntervalSeconds  uint64   `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds" json:"interval_seconds,omitempty"`
+	ByHost           bool     `protobuf:"varint,2,opt,name=by_host,json=byHost" json:"by_host,omitempty"`
+	ByConsumerMethod bool     `protobuf:"varint,3,opt,name=by_consumer_method,json=byConsumerMethod" json:"by_consumer_method,omitempty"`
+	IntervalMs       uint64   `protobuf:"varint,4,opt,name=interval_ms,json=intervalMs" json:"interval_ms,omitempty"`
+	Mode             StatMode `protobuf:"varint,5,opt,name=mode,enum=main.StatMode" json:"mode,omitempty"`
+	WindowSeconds    uint32   `protobuf:"varint,6,opt,name=window_seconds,json=windowSeconds" json:"window_seconds,omitempty"`
+}
+
+func (x *StatInterval) Reset()                    { *x = StatInterval{} }
+func (x *StatInterval) String() string            { return proto.CompactTextString(x) }
 func (*StatInterval) ProtoMessage()               {}
 func (*StatInterval) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
@@ -436,6 +1107,41 @@ func (x *StatInterval) GetIntervalSeconds() uint64 {
 	return 0
 }
 
+func (x *StatInterval) GetByHost() bool {
+	if x != nil {
+		return x.ByHost
+	}
+	return false
+}
+
+func (x *StatInterval) GetByConsumerMethod() bool {
+	if x != nil {
+		return x.ByConsumerMethod
+	}
+	return false
+}
+
+func (x *StatInterval) GetIntervalMs() uint64 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+func (x *StatInterval) GetMode() StatMode {
+	if x != nil {
+		return x.Mode
+	}
+	return StatMode_DELTA
+}
+
+func (x *StatInterval) GetWindowSeconds() uint32 {
+	if x != nil {
+		return x.WindowSeconds
+	}
+	return 0
+}
+
 type Nothing struct {
 	Dummy bool `protobuf:"varint,1,opt,name=dummy" json:"dummy,omitempty"`
 }
@@ -452,11 +1158,615 @@ func (x *Nothing) GetDummy() bool {
 	return false
 }
 
+type QuotaRequest struct {
+	Consumer string `protobuf:"bytes,1,opt,name=consumer" json:"consumer,omitempty"`
+}
+
+func (m *QuotaRequest) Reset()                    { *m = QuotaRequest{} }
+func (m *QuotaRequest) String() string            { return proto.CompactTextString(m) }
+func (*QuotaRequest) ProtoMessage()               {}
+func (*QuotaRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *QuotaRequest) GetConsumer() string {
+	if m != nil {
+		return m.Consumer
+	}
+	return ""
+}
+
+type QuotaUsage struct {
+	Consumer     string `protobuf:"bytes,1,opt,name=consumer" json:"consumer,omitempty"`
+	Method       string `protobuf:"bytes,2,opt,name=method" json:"method,omitempty"`
+	Day          string `protobuf:"bytes,3,opt,name=day" json:"day,omitempty"`
+	DailyUsed    uint64 `protobuf:"varint,4,opt,name=daily_used,json=dailyUsed" json:"daily_used,omitempty"`
+	DailyLimit   uint64 `protobuf:"varint,5,opt,name=daily_limit,json=dailyLimit" json:"daily_limit,omitempty"`
+	Month        string `protobuf:"bytes,6,opt,name=month" json:"month,omitempty"`
+	MonthlyUsed  uint64 `protobuf:"varint,7,opt,name=monthly_used,json=monthlyUsed" json:"monthly_used,omitempty"`
+	MonthlyLimit uint64 `protobuf:"varint,8,opt,name=monthly_limit,json=monthlyLimit" json:"monthly_limit,omitempty"`
+}
+
+func (m *QuotaUsage) Reset()                    { *m = QuotaUsage{} }
+func (m *QuotaUsage) String() string            { return proto.CompactTextString(m) }
+func (*QuotaUsage) ProtoMessage()               {}
+func (*QuotaUsage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *QuotaUsage) GetConsumer() string {
+	if m != nil {
+		return m.Consumer
+	}
+	return ""
+}
+
+func (m *QuotaUsage) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *QuotaUsage) GetDay() string {
+	if m != nil {
+		return m.Day
+	}
+	return ""
+}
+
+func (m *QuotaUsage) GetDailyUsed() uint64 {
+	if m != nil {
+		return m.DailyUsed
+	}
+	return 0
+}
+
+func (m *QuotaUsage) GetDailyLimit() uint64 {
+	if m != nil {
+		return m.DailyLimit
+	}
+	return 0
+}
+
+func (m *QuotaUsage) GetMonth() string {
+	if m != nil {
+		return m.Month
+	}
+	return ""
+}
+
+func (m *QuotaUsage) GetMonthlyUsed() uint64 {
+	if m != nil {
+		return m.MonthlyUsed
+	}
+	return 0
+}
+
+func (m *QuotaUsage) GetMonthlyLimit() uint64 {
+	if m != nil {
+		return m.MonthlyLimit
+	}
+	return 0
+}
+
+type QuotaReport struct {
+	Usage []*QuotaUsage `protobuf:"bytes,1,rep,name=usage" json:"usage,omitempty"`
+}
+
+func (m *QuotaReport) Reset()                    { *m = QuotaReport{} }
+func (m *QuotaReport) String() string            { return proto.CompactTextString(m) }
+func (*QuotaReport) ProtoMessage()               {}
+func (*QuotaReport) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *QuotaReport) GetUsage() []*QuotaUsage {
+	if m != nil {
+		return m.Usage
+	}
+	return nil
+}
+
+type ExplainRequest struct {
+	Consumer string `protobuf:"bytes,1,opt,name=consumer" json:"consumer,omitempty"`
+	Method   string `protobuf:"bytes,2,opt,name=method" json:"method,omitempty"`
+}
+
+func (m *ExplainRequest) Reset()                    { *m = ExplainRequest{} }
+func (m *ExplainRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExplainRequest) ProtoMessage()               {}
+func (*ExplainRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ExplainRequest) GetConsumer() string {
+	if m != nil {
+		return m.Consumer
+	}
+	return ""
+}
+
+func (m *ExplainRequest) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+type AclRule struct {
+	Pattern string `protobuf:"bytes,1,opt,name=pattern" json:"pattern,omitempty"`
+	Deny    bool   `protobuf:"varint,2,opt,name=deny" json:"deny,omitempty"`
+	Entry   string `protobuf:"bytes,3,opt,name=entry" json:"entry,omitempty"`
+	Role    string `protobuf:"bytes,4,opt,name=role" json:"role,omitempty"`
+}
+
+func (m *AclRule) Reset()                    { *m = AclRule{} }
+func (m *AclRule) String() string            { return proto.CompactTextString(m) }
+func (*AclRule) ProtoMessage()               {}
+func (*AclRule) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *AclRule) GetPattern() string {
+	if m != nil {
+		return m.Pattern
+	}
+	return ""
+}
+
+func (m *AclRule) GetDeny() bool {
+	if m != nil {
+		return m.Deny
+	}
+	return false
+}
+
+func (m *AclRule) GetEntry() string {
+	if m != nil {
+		return m.Entry
+	}
+	return ""
+}
+
+func (m *AclRule) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+type ExplainReply struct {
+	Allowed bool       `protobuf:"varint,1,opt,name=allowed" json:"allowed,omitempty"`
+	Rules   []*AclRule `protobuf:"bytes,2,rep,name=rules" json:"rules,omitempty"`
+	Reason  string     `protobuf:"bytes,3,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *ExplainReply) Reset()                    { *m = ExplainReply{} }
+func (m *ExplainReply) String() string            { return proto.CompactTextString(m) }
+func (*ExplainReply) ProtoMessage()               {}
+func (*ExplainReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ExplainReply) GetAllowed() bool {
+	if m != nil {
+		return m.Allowed
+	}
+	return false
+}
+
+func (m *ExplainReply) GetRules() []*AclRule {
+	if m != nil {
+		return m.Rules
+	}
+	return nil
+}
+
+func (m *ExplainReply) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type LogRequest struct {
+	Consumers     []string       `protobuf:"bytes,2,rep,name=consumers" json:"consumers,omitempty"`
+	MethodPattern string         `protobuf:"bytes,3,opt,name=method_pattern,json=methodPattern" json:"method_pattern,omitempty"`
+	Hosts         []string       `protobuf:"bytes,4,rep,name=hosts" json:"hosts,omitempty"`
+	Outcomes      []Outcome      `protobuf:"varint,5,rep,packed,name=outcomes,enum=main.Outcome" json:"outcomes,omitempty"`
+	BufferSize    uint32         `protobuf:"varint,6,opt,name=buffer_size,json=bufferSize" json:"buffer_size,omitempty"`
+	Overflow      OverflowPolicy `protobuf:"varint,7,opt,name=overflow,enum=main.OverflowPolicy" json:"overflow,omitempty"`
+	ReplayLast    uint32         `protobuf:"varint,8,opt,name=replay_last,json=replayLast" json:"replay_last,omitempty"`
+	ReplaySince   int64          `protobuf:"varint,9,opt,name=replay_since,json=replaySince" json:"replay_since,omitempty"`
+	ResumeAfter   uint64         `protobuf:"varint,10,opt,name=resume_after,json=resumeAfter" json:"resume_after,omitempty"`
+}
+
+func (m *LogRequest) Reset()                    { *m = LogRequest{} }
+func (m *LogRequest) String() string            { return proto.CompactTextString(m) }
+func (*LogRequest) ProtoMessage()               {}
+func (*LogRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *LogRequest) GetConsumers() []string {
+	if m != nil {
+		return m.Consumers
+	}
+	return nil
+}
+
+func (m *LogRequest) GetMethodPattern() string {
+	if m != nil {
+		return m.MethodPattern
+	}
+	return ""
+}
+
+func (m *LogRequest) GetHosts() []string {
+	if m != nil {
+		return m.Hosts
+	}
+	return nil
+}
+
+func (m *LogRequest) GetOutcomes() []Outcome {
+	if m != nil {
+		return m.Outcomes
+	}
+	return nil
+}
+
+func (m *LogRequest) GetBufferSize() uint32 {
+	if m != nil {
+		return m.BufferSize
+	}
+	return 0
+}
+
+func (m *LogRequest) GetOverflow() OverflowPolicy {
+	if m != nil {
+		return m.Overflow
+	}
+	return OverflowPolicy_POLICY_DEFAULT
+}
+
+func (m *LogRequest) GetReplayLast() uint32 {
+	if m != nil {
+		return m.ReplayLast
+	}
+	return 0
+}
+
+func (m *LogRequest) GetReplaySince() int64 {
+	if m != nil {
+		return m.ReplaySince
+	}
+	return 0
+}
+
+func (m *LogRequest) GetResumeAfter() uint64 {
+	if m != nil {
+		return m.ResumeAfter
+	}
+	return 0
+}
+
+type LatencyHistogram struct {
+	Bounds []float64 `protobuf:"fixed64,1,rep,packed,name=bounds" json:"bounds,omitempty"`
+	Counts []uint64  `protobuf:"varint,2,rep,packed,name=counts" json:"counts,omitempty"`
+	Count  uint64    `protobuf:"varint,3,opt,name=count" json:"count,omitempty"`
+	Sum    float64   `protobuf:"fixed64,4,opt,name=sum" json:"sum,omitempty"`
+	P50    float64   `protobuf:"fixed64,5,opt,name=p50" json:"p50,omitempty"`
+	P90    float64   `protobuf:"fixed64,6,opt,name=p90" json:"p90,omitempty"`
+	P99    float64   `protobuf:"fixed64,7,opt,name=p99" json:"p99,omitempty"`
+}
+
+func (m *LatencyHistogram) Reset()                    { *m = LatencyHistogram{} }
+func (m *LatencyHistogram) String() string            { return proto.CompactTextString(m) }
+func (*LatencyHistogram) ProtoMessage()               {}
+func (*LatencyHistogram) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *LatencyHistogram) GetBounds() []float64 {
+	if m != nil {
+		return m.Bounds
+	}
+	return nil
+}
+
+func (m *LatencyHistogram) GetCounts() []uint64 {
+	if m != nil {
+		return m.Counts
+	}
+	return nil
+}
+
+func (m *LatencyHistogram) GetCount() uint64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *LatencyHistogram) GetSum() float64 {
+	if m != nil {
+		return m.Sum
+	}
+	return 0
+}
+
+func (m *LatencyHistogram) GetP50() float64 {
+	if m != nil {
+		return m.P50
+	}
+	return 0
+}
+
+func (m *LatencyHistogram) GetP90() float64 {
+	if m != nil {
+		return m.P90
+	}
+	return 0
+}
+
+func (m *LatencyHistogram) GetP99() float64 {
+	if m != nil {
+		return m.P99
+	}
+	return 0
+}
+
+type CodeCounts struct {
+	ByCode map[string]uint64 `protobuf:"bytes,1,rep,name=by_code,json=byCode" json:"by_code,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (m *CodeCounts) Reset()                    { *m = CodeCounts{} }
+func (m *CodeCounts) String() string            { return proto.CompactTextString(m) }
+func (*CodeCounts) ProtoMessage()               {}
+func (*CodeCounts) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *CodeCounts) GetByCode() map[string]uint64 {
+	if m != nil {
+		return m.ByCode
+	}
+	return nil
+}
+
+type MethodCounts struct {
+	ByMethod map[string]uint64 `protobuf:"bytes,1,rep,name=by_method,json=byMethod" json:"by_method,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (m *MethodCounts) Reset()                    { *m = MethodCounts{} }
+func (m *MethodCounts) String() string            { return proto.CompactTextString(m) }
+func (*MethodCounts) ProtoMessage()               {}
+func (*MethodCounts) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *MethodCounts) GetByMethod() map[string]uint64 {
+	if m != nil {
+		return m.ByMethod
+	}
+	return nil
+}
+
+type StatsRequest struct {
+	Consumers        []string `protobuf:"bytes,1,rep,name=consumers" json:"consumers,omitempty"`
+	Methods          []string `protobuf:"bytes,2,rep,name=methods" json:"methods,omitempty"`
+	WindowSeconds    uint32   `protobuf:"varint,3,opt,name=window_seconds,json=windowSeconds" json:"window_seconds,omitempty"`
+	ByHost           bool     `protobuf:"varint,4,opt,name=by_host,json=byHost" json:"by_host,omitempty"`
+	ByConsumerMethod bool     `protobuf:"varint,5,opt,name=by_consumer_method,json=byConsumerMethod" json:"by_consumer_method,omitempty"`
+}
+
+func (m *StatsRequest) Reset()                    { *m = StatsRequest{} }
+func (m *StatsRequest) String() string            { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()               {}
+func (*StatsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *StatsRequest) GetConsumers() []string {
+	if m != nil {
+		return m.Consumers
+	}
+	return nil
+}
+
+func (m *StatsRequest) GetMethods() []string {
+	if m != nil {
+		return m.Methods
+	}
+	return nil
+}
+
+func (m *StatsRequest) GetWindowSeconds() uint32 {
+	if m != nil {
+		return m.WindowSeconds
+	}
+	return 0
+}
+
+func (m *StatsRequest) GetByHost() bool {
+	if m != nil {
+		return m.ByHost
+	}
+	return false
+}
+
+func (m *StatsRequest) GetByConsumerMethod() bool {
+	if m != nil {
+		return m.ByConsumerMethod
+	}
+	return false
+}
+
+type StatsReply struct {
+	Cumulative *Stat `protobuf:"bytes,1,opt,name=cumulative" json:"cumulative,omitempty"`
+	Window     *Stat `protobuf:"bytes,2,opt,name=window" json:"window,omitempty"`
+}
+
+func (m *StatsReply) Reset()                    { *m = StatsReply{} }
+func (m *StatsReply) String() string            { return proto.CompactTextString(m) }
+func (*StatsReply) ProtoMessage()               {}
+func (*StatsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *StatsReply) GetCumulative() *Stat {
+	if m != nil {
+		return m.Cumulative
+	}
+	return nil
+}
+
+func (m *StatsReply) GetWindow() *Stat {
+	if m != nil {
+		return m.Window
+	}
+	return nil
+}
+
+type TopRequest struct {
+	N             uint32   `protobuf:"varint,1,opt,name=n" json:"n,omitempty"`
+	Order         TopOrder `protobuf:"varint,2,opt,name=order,enum=main.TopOrder" json:"order,omitempty"`
+	WindowSeconds uint32   `protobuf:"varint,3,opt,name=window_seconds,json=windowSeconds" json:"window_seconds,omitempty"`
+}
+
+func (m *TopRequest) Reset()                    { *m = TopRequest{} }
+func (m *TopRequest) String() string            { return proto.CompactTextString(m) }
+func (*TopRequest) ProtoMessage()               {}
+func (*TopRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *TopRequest) GetN() uint32 {
+	if m != nil {
+		return m.N
+	}
+	return 0
+}
+
+func (m *TopRequest) GetOrder() TopOrder {
+	if m != nil {
+		return m.Order
+	}
+	return TopOrder_CALLS
+}
+
+func (m *TopRequest) GetWindowSeconds() uint32 {
+	if m != nil {
+		return m.WindowSeconds
+	}
+	return 0
+}
+
+type TopEntry struct {
+	Name    string  `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Calls   uint64  `protobuf:"varint,2,opt,name=calls" json:"calls,omitempty"`
+	Errors  uint64  `protobuf:"varint,3,opt,name=errors" json:"errors,omitempty"`
+	Latency float64 `protobuf:"fixed64,4,opt,name=latency" json:"latency,omitempty"`
+}
+
+func (m *TopEntry) Reset()                    { *m = TopEntry{} }
+func (m *TopEntry) String() string            { return proto.CompactTextString(m) }
+func (*TopEntry) ProtoMessage()               {}
+func (*TopEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *TopEntry) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *TopEntry) GetCalls() uint64 {
+	if m != nil {
+		return m.Calls
+	}
+	return 0
+}
+
+func (m *TopEntry) GetErrors() uint64 {
+	if m != nil {
+		return m.Errors
+	}
+	return 0
+}
+
+func (m *TopEntry) GetLatency() float64 {
+	if m != nil {
+		return m.Latency
+	}
+	return 0
+}
+
+type TopReport struct {
+	Timestamp int64       `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Consumers []*TopEntry `protobuf:"bytes,2,rep,name=consumers" json:"consumers,omitempty"`
+	Methods   []*TopEntry `protobuf:"bytes,3,rep,name=methods" json:"methods,omitempty"`
+	Hosts     []*TopEntry `protobuf:"bytes,4,rep,name=hosts" json:"hosts,omitempty"`
+}
+
+func (m *TopReport) Reset()                    { *m = TopReport{} }
+func (m *TopReport) String() string            { return proto.CompactTextString(m) }
+func (*TopReport) ProtoMessage()               {}
+func (*TopReport) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *TopReport) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *TopReport) GetConsumers() []*TopEntry {
+	if m != nil {
+		return m.Consumers
+	}
+	return nil
+}
+
+func (m *TopReport) GetMethods() []*TopEntry {
+	if m != nil {
+		return m.Methods
+	}
+	return nil
+}
+
+func (m *TopReport) GetHosts() []*TopEntry {
+	if m != nil {
+		return m.Hosts
+	}
+	return nil
+}
+
+type StatTotals struct {
+	Totals     *Stat            `protobuf:"bytes,1,opt,name=totals" json:"totals,omitempty"`
+	ByConsumer map[string]*Stat `protobuf:"bytes,2,rep,name=by_consumer,json=byConsumer" json:"by_consumer,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *StatTotals) Reset()                    { *m = StatTotals{} }
+func (m *StatTotals) String() string            { return proto.CompactTextString(m) }
+func (*StatTotals) ProtoMessage()               {}
+func (*StatTotals) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *StatTotals) GetTotals() *Stat {
+	if m != nil {
+		return m.Totals
+	}
+	return nil
+}
+
+func (m *StatTotals) GetByConsumer() map[string]*Stat {
+	if m != nil {
+		return m.ByConsumer
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Event)(nil), "main.Event")
 	proto.RegisterType((*Stat)(nil), "main.Stat")
 	proto.RegisterType((*StatInterval)(nil), "main.StatInterval")
 	proto.RegisterType((*Nothing)(nil), "main.Nothing")
+	proto.RegisterType((*QuotaRequest)(nil), "main.QuotaRequest")
+	proto.RegisterType((*QuotaUsage)(nil), "main.QuotaUsage")
+	proto.RegisterType((*QuotaReport)(nil), "main.QuotaReport")
+	proto.RegisterType((*ExplainRequest)(nil), "main.ExplainRequest")
+	proto.RegisterType((*AclRule)(nil), "main.AclRule")
+	proto.RegisterType((*ExplainReply)(nil), "main.ExplainReply")
+	proto.RegisterType((*LogRequest)(nil), "main.LogRequest")
+	proto.RegisterType((*LatencyHistogram)(nil), "main.LatencyHistogram")
+	proto.RegisterType((*CodeCounts)(nil), "main.CodeCounts")
+	proto.RegisterType((*MethodCounts)(nil), "main.MethodCounts")
+	proto.RegisterType((*StatsRequest)(nil), "main.StatsRequest")
+	proto.RegisterType((*StatsReply)(nil), "main.StatsReply")
+	proto.RegisterType((*TopRequest)(nil), "main.TopRequest")
+	proto.RegisterType((*TopEntry)(nil), "main.TopEntry")
+	proto.RegisterType((*TopReport)(nil), "main.TopReport")
+	proto.RegisterType((*StatTotals)(nil), "main.StatTotals")
+	proto.RegisterEnum("main.Outcome", Outcome_name, Outcome_value)
+	proto.RegisterEnum("main.OverflowPolicy", OverflowPolicy_name, OverflowPolicy_value)
+	proto.RegisterEnum("main.StatMode", StatMode_name, StatMode_value)
+	proto.RegisterEnum("main.TopOrder", TopOrder_name, TopOrder_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -470,8 +1780,12 @@ const _ = grpc.SupportPackageIsVersion4
 // Client API for Admin service
 
 type AdminClient interface {
-	Logging(ctx context.Context, in *Nothing, opts ...grpc.CallOption) (Admin_LoggingClient, error)
+	Logging(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (Admin_LoggingClient, error)
 	Statistics(ctx context.Context, in *StatInterval, opts ...grpc.CallOption) (Admin_StatisticsClient, error)
+	GetQuota(ctx context.Context, in *QuotaRequest, opts ...grpc.CallOption) (*QuotaReport, error)
+	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainReply, error)
+	GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error)
+	GetTop(ctx context.Context, in *TopRequest, opts ...grpc.CallOption) (*TopReport, error)
 }
 
 type adminClient struct {
@@ -482,7 +1796,7 @@ func NewAdminClient(cc *grpc.ClientConn) AdminClient {
 	return &adminClient{cc}
 }
 
-func (c *adminClient) Logging(ctx context.Context, in *Nothing, opts ...grpc.CallOption) (Admin_LoggingClient, error) {
+func (c *adminClient) Logging(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (Admin_LoggingClient, error) {
 	stream, err := grpc.NewClientStream(ctx, &_Admin_serviceDesc.Streams[0], c.cc, "/main.Admin/Logging", opts...)
 	if err != nil {
 		return nil, err
@@ -546,11 +1860,51 @@ func (x *adminStatisticsClient) Recv() (*Stat, error) {
 	return m, nil
 }
 
+func (c *adminClient) GetQuota(ctx context.Context, in *QuotaRequest, opts ...grpc.CallOption) (*QuotaReport, error) {
+	out := new(QuotaReport)
+	err := grpc.Invoke(ctx, "/main.Admin/GetQuota", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainReply, error) {
+	out := new(ExplainReply)
+	err := grpc.Invoke(ctx, "/main.Admin/Explain", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error) {
+	out := new(StatsReply)
+	err := grpc.Invoke(ctx, "/main.Admin/GetStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetTop(ctx context.Context, in *TopRequest, opts ...grpc.CallOption) (*TopReport, error) {
+	out := new(TopReport)
+	err := grpc.Invoke(ctx, "/main.Admin/GetTop", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Admin service
 
 type AdminServer interface {
-	Logging(*Nothing, Admin_LoggingServer) error
+	Logging(*LogRequest, Admin_LoggingServer) error
 	Statistics(*StatInterval, Admin_StatisticsServer) error
+	GetQuota(context.Context, *QuotaRequest) (*QuotaReport, error)
+	Explain(context.Context, *ExplainRequest) (*ExplainReply, error)
+	GetStats(context.Context, *StatsRequest) (*StatsReply, error)
+	GetTop(context.Context, *TopRequest) (*TopReport, error)
 }
 
 func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
@@ -558,7 +1912,7 @@ func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
 }
 
 func _Admin_Logging_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Nothing)
+	m := new(LogRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
@@ -599,10 +1953,99 @@ func (x *adminStatisticsServer) Send(m *Stat) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Admin_GetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/main.Admin/GetQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetQuota(ctx, req.(*QuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/main.Admin/Explain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Explain(ctx, req.(*ExplainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/main.Admin/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetStats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetTop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetTop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/main.Admin/GetTop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetTop(ctx, req.(*TopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Admin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "main.Admin",
 	HandlerType: (*AdminServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetQuota",
+			Handler:    _Admin_GetQuota_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _Admin_Explain_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Admin_GetStats_Handler,
+		},
+		{
+			MethodName: "GetTop",
+			Handler:    _Admin_GetTop_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "Logging",
@@ -751,30 +2194,119 @@ var _Biz_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("service.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 386 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x52, 0x5d, 0xab, 0xda, 0x40,
-	0x10, 0xbd, 0xf9, 0xba, 0xd7, 0x8c, 0x95, 0x7b, 0x19, 0x4a, 0x09, 0xa1, 0x50, 0x09, 0xb4, 0xf5,
-	0xbe, 0x04, 0xb1, 0x14, 0xda, 0x4a, 0x1f, 0x54, 0x7c, 0x28, 0xb4, 0x7d, 0x88, 0x7d, 0x97, 0x7c,
-	0x2c, 0x66, 0xd1, 0xdd, 0x95, 0xec, 0x1a, 0x48, 0xa1, 0xff, 0xa2, 0x3f, 0xb8, 0xec, 0x26, 0x2a,
-	0xfa, 0x22, 0x7d, 0x9b, 0x73, 0x66, 0xce, 0x99, 0xc3, 0x30, 0x30, 0x90, 0xa4, 0xaa, 0x69, 0x4e,
-	0xe2, 0x7d, 0x25, 0x94, 0x40, 0x97, 0xa5, 0x94, 0x47, 0x0c, 0xbc, 0x65, 0x4d, 0xb8, 0xc2, 0xd7,
-	0xe0, 0x2b, 0xca, 0x88, 0x54, 0x29, 0xdb, 0x07, 0xd6, 0xd0, 0x1a, 0x39, 0xc9, 0x99, 0xc0, 0x10,
-	0x7a, 0xb9, 0xe0, 0xf2, 0xc0, 0x48, 0x15, 0xd8, 0x43, 0x6b, 0xe4, 0x27, 0x27, 0x8c, 0xaf, 0xe0,
-	0x9e, 0x11, 0x55, 0x8a, 0x22, 0x70, 0x4c, 0xa7, 0x43, 0x88, 0xe0, 0x96, 0x42, 0xaa, 0xc0, 0x35,
-	0xac, 0xa9, 0xa3, 0xbf, 0x36, 0xb8, 0x2b, 0x95, 0xde, 0x5a, 0xf7, 0x11, 0xfc, 0xac, 0x59, 0x77,
-	0xae, 0xf6, 0xd0, 0x19, 0xf5, 0x27, 0x41, 0xac, 0xf3, 0xc6, 0x5a, 0x1c, 0xcf, 0x9b, 0x1f, 0xa6,
-	0xb5, 0xe4, 0xaa, 0x6a, 0x92, 0x5e, 0xd6, 0x41, 0x9c, 0x42, 0x3f, 0x6b, 0xd6, 0xa7, 0xa0, 0x8e,
-	0x11, 0x86, 0x17, 0xc2, 0x45, 0xd7, 0x6c, 0xa5, 0x90, 0x9d, 0x88, 0x70, 0x0a, 0x83, 0x0b, 0x5f,
-	0x7c, 0x02, 0x67, 0x4b, 0x1a, 0x13, 0xce, 0x4f, 0x74, 0x89, 0x2f, 0xc1, 0xab, 0xd3, 0xdd, 0x81,
-	0x98, 0x13, 0xb8, 0x49, 0x0b, 0xbe, 0xd8, 0x9f, 0xac, 0xf0, 0x2b, 0x3c, 0x5e, 0x79, 0xff, 0x8f,
-	0x3c, 0xfa, 0x0c, 0x2f, 0x74, 0xbe, 0x6f, 0x5c, 0x91, 0xaa, 0x4e, 0x77, 0xf8, 0x0c, 0x4f, 0xb4,
-	0xab, 0xd7, 0x92, 0xe4, 0x82, 0x17, 0xd2, 0x18, 0xb9, 0xc9, 0xe3, 0x91, 0x5f, 0xb5, 0x74, 0xf4,
-	0x06, 0x1e, 0x7e, 0x0a, 0x55, 0x52, 0xbe, 0xd1, 0xfe, 0xc5, 0x81, 0xb1, 0x76, 0x67, 0x2f, 0x69,
-	0xc1, 0xa4, 0x00, 0x6f, 0x56, 0x30, 0xca, 0xf1, 0x19, 0x1e, 0xbe, 0x8b, 0xcd, 0x46, 0x4f, 0x0e,
-	0xda, 0x9b, 0x74, 0xc2, 0xb0, 0xdf, 0x42, 0xf3, 0x08, 0xd1, 0xdd, 0xd8, 0xc2, 0x31, 0x80, 0xce,
-	0x43, 0xa5, 0xa2, 0xb9, 0x44, 0x3c, 0x5f, 0xf0, 0x98, 0x30, 0x84, 0x33, 0xa7, 0x15, 0x93, 0x3f,
-	0xe0, 0xcc, 0xe9, 0x6f, 0x7c, 0x0f, 0xde, 0xa2, 0x24, 0xf9, 0xf6, 0x7a, 0xc3, 0x25, 0x8c, 0xee,
-	0xf0, 0x2d, 0x38, 0xb3, 0xa2, 0xb8, 0x39, 0xf6, 0x0e, 0xdc, 0x5f, 0x44, 0xaa, 0x5b, 0x73, 0xd9,
-	0xbd, 0xf9, 0xe9, 0x0f, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x03, 0x1d, 0xb2, 0x19, 0xe4, 0x02,
-	0x00, 0x00,
+	// 1811 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0xef, 0x6e, 0xe3, 0xc6,
+	0x11, 0x37, 0x25, 0xea, 0xdf, 0xe8, 0x8f, 0x79, 0xdb, 0x6b, 0x4a, 0x08, 0x57, 0x9c, 0xca, 0x6b,
+	0x12, 0xc5, 0xb8, 0x3a, 0x86, 0x83, 0x03, 0xea, 0x1e, 0xf2, 0x41, 0x96, 0x98, 0x9c, 0x52, 0x5a,
+	0x72, 0xd6, 0x72, 0x0e, 0x09, 0x0a, 0x08, 0x94, 0xb8, 0xb6, 0x89, 0x90, 0x5c, 0x85, 0x7f, 0x7c,
+	0xd5, 0x01, 0xed, 0x03, 0xf4, 0x29, 0xfa, 0xa5, 0xe8, 0x0b, 0x14, 0xfd, 0xd8, 0xc7, 0x28, 0xfa,
+	0x10, 0xfd, 0xd0, 0x47, 0x28, 0xf6, 0x0f, 0x45, 0x52, 0xd6, 0xdd, 0xf9, 0x70, 0xdf, 0x76, 0x7e,
+	0x3b, 0x33, 0x3b, 0x33, 0x3b, 0x3b, 0x33, 0x24, 0xb4, 0x23, 0x12, 0xde, 0xba, 0x4b, 0x72, 0xb8,
+	0x0a, 0x69, 0x4c, 0x91, 0xea, 0xdb, 0x6e, 0x60, 0xfc, 0xbb, 0x04, 0x15, 0xf3, 0x96, 0x04, 0x31,
+	0x7a, 0x04, 0x8d, 0xd8, 0xf5, 0x49, 0x14, 0xdb, 0xfe, 0x4a, 0x57, 0x7a, 0x4a, 0xbf, 0x8c, 0x33,
+	0x00, 0x75, 0xa1, 0xbe, 0xa4, 0x41, 0x94, 0xf8, 0x24, 0xd4, 0x4b, 0x3d, 0xa5, 0xdf, 0xc0, 0x1b,
+	0x1a, 0x7d, 0x04, 0x55, 0x9f, 0xc4, 0x37, 0xd4, 0xd1, 0xcb, 0x7c, 0x47, 0x52, 0x08, 0x81, 0x7a,
+	0x43, 0xa3, 0x58, 0x57, 0x39, 0xca, 0xd7, 0xe8, 0x53, 0xa8, 0xd1, 0x24, 0x5e, 0x52, 0x9f, 0xe8,
+	0x95, 0x9e, 0xd2, 0xef, 0x1c, 0xb7, 0x0f, 0x99, 0x1d, 0x87, 0x53, 0x01, 0xe2, 0x74, 0x97, 0x09,
+	0x2f, 0xa9, 0x43, 0xf4, 0x6a, 0x4f, 0xe9, 0xb7, 0x31, 0x5f, 0xb3, 0x83, 0x42, 0x62, 0x47, 0x34,
+	0xd0, 0x6b, 0xe2, 0x20, 0x41, 0x21, 0x1d, 0x6a, 0x4e, 0x48, 0x57, 0x2b, 0xe2, 0xe8, 0xf5, 0x9e,
+	0xd2, 0x57, 0x71, 0x4a, 0x22, 0x0d, 0xca, 0x11, 0xf9, 0x49, 0x6f, 0x70, 0x94, 0x2d, 0x99, 0x23,
+	0x4e, 0x12, 0xda, 0xb1, 0x4b, 0x03, 0x1d, 0xb8, 0x97, 0x1b, 0x1a, 0x3d, 0x81, 0x76, 0x48, 0x7e,
+	0x4a, 0x48, 0x14, 0xcf, 0x17, 0xeb, 0x98, 0x44, 0x7a, 0x93, 0xcb, 0xb5, 0x24, 0x78, 0xca, 0x30,
+	0xf4, 0x31, 0x74, 0x42, 0x12, 0xad, 0x68, 0x10, 0x11, 0xc9, 0xd5, 0xe2, 0x5c, 0xed, 0x14, 0xe5,
+	0x6c, 0xc6, 0xdf, 0xea, 0xa0, 0x5e, 0xc4, 0xf6, 0xbb, 0xe2, 0xfa, 0x0c, 0x1a, 0x8b, 0xf5, 0x5c,
+	0x86, 0xaf, 0xd4, 0x2b, 0xf7, 0x9b, 0xc7, 0xba, 0x88, 0x08, 0x13, 0x3e, 0x3c, 0x5d, 0x9f, 0xf1,
+	0x2d, 0x33, 0x88, 0xc3, 0x35, 0xae, 0x2f, 0x24, 0x89, 0x9e, 0x43, 0x73, 0xb1, 0x9e, 0x6f, 0x6e,
+	0xa4, 0xcc, 0x05, 0xbb, 0x05, 0xc1, 0xa1, 0xdc, 0x14, 0xa2, 0xb0, 0xd8, 0x00, 0xe8, 0xf7, 0xf0,
+	0xc0, 0xb3, 0x63, 0x12, 0x2c, 0xd7, 0xf3, 0xec, 0x6c, 0x95, 0xab, 0x78, 0x9c, 0x53, 0x61, 0x09,
+	0x9e, 0xa2, 0x09, 0xfb, 0x5e, 0x11, 0x45, 0x9f, 0x43, 0x8d, 0x5b, 0xe2, 0xb0, 0x0b, 0x65, 0x2a,
+	0x3e, 0xda, 0xb2, 0xc2, 0x21, 0x42, 0xb2, 0xba, 0xe0, 0x04, 0x3a, 0x85, 0xce, 0xe6, 0xd4, 0xb9,
+	0xbc, 0x62, 0x26, 0xf7, 0x68, 0x87, 0xdb, 0x99, 0x74, 0x6b, 0x91, 0x83, 0xe4, 0xa1, 0x3c, 0xb9,
+	0x6a, 0x3b, 0x0e, 0x7d, 0x41, 0xa3, 0x78, 0x73, 0x28, 0x23, 0xd0, 0x04, 0x50, 0x2e, 0x5e, 0xa9,
+	0xcf, 0x75, 0x2e, 0xdb, 0xdb, 0x19, 0xb6, 0xbc, 0xd3, 0xda, 0x62, 0x0b, 0x46, 0x07, 0x00, 0xcb,
+	0xc4, 0x4f, 0x3c, 0x3b, 0x76, 0x6f, 0x09, 0x4f, 0xaf, 0xe6, 0x31, 0x64, 0x7a, 0x70, 0x6e, 0x17,
+	0x19, 0x50, 0x7d, 0xe5, 0x06, 0x0e, 0x7d, 0xa5, 0xc3, 0x1d, 0x3e, 0xb9, 0xd3, 0x7d, 0x0e, 0xed,
+	0x42, 0x9c, 0x59, 0xe2, 0xfe, 0x48, 0xd6, 0x3c, 0x5f, 0x1a, 0x98, 0x2d, 0xd1, 0x43, 0xa8, 0xdc,
+	0xda, 0x5e, 0x42, 0xf8, 0xf3, 0x53, 0xb1, 0x20, 0x7e, 0x57, 0xfa, 0xad, 0xd2, 0xfd, 0x12, 0xf6,
+	0xb7, 0xae, 0xfb, 0xbd, 0xc4, 0x7f, 0x80, 0x87, 0xbb, 0xae, 0x7a, 0x87, 0x8e, 0xa7, 0x79, 0x1d,
+	0x9b, 0xa0, 0x4b, 0xe1, 0x17, 0x6e, 0x14, 0xd3, 0xeb, 0xd0, 0xf6, 0xf3, 0xba, 0x4f, 0xa0, 0x99,
+	0xcb, 0x81, 0xf7, 0x32, 0xeb, 0x5b, 0x78, 0x70, 0x27, 0x0d, 0x76, 0x28, 0xf8, 0xa4, 0x68, 0x93,
+	0x26, 0x6c, 0x62, 0x12, 0x43, 0x9a, 0x04, 0x71, 0x74, 0xc7, 0x9a, 0x4d, 0x72, 0xbc, 0x97, 0x35,
+	0x2f, 0xe1, 0xe7, 0x3b, 0x73, 0x63, 0x87, 0x92, 0x7e, 0xd1, 0x22, 0x24, 0x2c, 0x4a, 0x3d, 0xd9,
+	0xb2, 0xc9, 0xf8, 0xaf, 0x02, 0x2d, 0x96, 0x0a, 0xe3, 0x20, 0x26, 0xe1, 0xad, 0xed, 0xa1, 0xcf,
+	0x40, 0x73, 0xe5, 0x7a, 0x1e, 0x91, 0x25, 0x0d, 0x9c, 0x88, 0x6b, 0x57, 0xf1, 0x7e, 0x8a, 0x5f,
+	0x08, 0x18, 0xfd, 0x22, 0x7b, 0x06, 0xec, 0xac, 0xfa, 0x26, 0xdd, 0x9f, 0xee, 0x4c, 0xf7, 0x32,
+	0xe7, 0xb9, 0x9b, 0xcc, 0x8f, 0xa1, 0xb9, 0x39, 0xd1, 0x8f, 0x78, 0xb9, 0x56, 0x31, 0xa4, 0xd0,
+	0x59, 0x84, 0x0c, 0x50, 0x7d, 0xea, 0xa4, 0x15, 0xbb, 0x93, 0xe5, 0xef, 0x19, 0x75, 0x08, 0xe6,
+	0x7b, 0xac, 0x2c, 0x8a, 0x5c, 0xde, 0x18, 0x2d, 0x2a, 0x77, 0x5b, 0xa0, 0xd2, 0x64, 0xe3, 0x31,
+	0xd4, 0x26, 0x34, 0xbe, 0x71, 0x83, 0x6b, 0x16, 0x6c, 0x27, 0xf1, 0x7d, 0x11, 0xbb, 0x3a, 0x16,
+	0x84, 0x71, 0x00, 0xad, 0x6f, 0x13, 0x1a, 0xdb, 0x58, 0xd4, 0xdc, 0x42, 0xe3, 0x51, 0x8a, 0x8d,
+	0xc7, 0xf8, 0x9f, 0x02, 0xc0, 0x99, 0x2f, 0x23, 0xfb, 0x9a, 0xbc, 0x8d, 0x35, 0xd7, 0xa3, 0x4a,
+	0x85, 0x1e, 0xa5, 0x41, 0xd9, 0xb1, 0xd7, 0xb2, 0x71, 0xb1, 0x25, 0xfa, 0x25, 0x80, 0x63, 0xbb,
+	0xde, 0x7a, 0x9e, 0x44, 0xc4, 0x91, 0xc1, 0x68, 0x70, 0xe4, 0x32, 0x22, 0x3c, 0x58, 0x62, 0xdb,
+	0x73, 0x7d, 0x37, 0xe6, 0x21, 0x51, 0xb1, 0x90, 0xb0, 0x18, 0xc2, 0xdc, 0xf2, 0x69, 0x10, 0xdf,
+	0x70, 0xff, 0x1b, 0x58, 0x10, 0xe8, 0x57, 0xd0, 0xe2, 0x8b, 0x54, 0x6f, 0x8d, 0xcb, 0x35, 0x25,
+	0xc6, 0x35, 0x3f, 0x81, 0x76, 0xca, 0x22, 0x74, 0x8b, 0x5e, 0x96, 0xca, 0x71, 0xed, 0xc6, 0x33,
+	0x68, 0xca, 0xf0, 0xac, 0x68, 0x18, 0xb3, 0xec, 0x4f, 0x98, 0xef, 0xba, 0xd2, 0x2b, 0x67, 0xd9,
+	0x9f, 0xc5, 0x04, 0x8b, 0x6d, 0x63, 0x04, 0x1d, 0xf3, 0x8f, 0x2b, 0xcf, 0x76, 0x83, 0x7b, 0xc4,
+	0xf5, 0x4d, 0xc1, 0x32, 0x6c, 0xa8, 0x0d, 0x96, 0x1e, 0x4e, 0x3c, 0xc2, 0x5a, 0xee, 0xca, 0x8e,
+	0x63, 0x12, 0x06, 0x52, 0x3a, 0x25, 0x59, 0xe3, 0x76, 0x48, 0xb0, 0x96, 0x19, 0xc9, 0xd7, 0x2c,
+	0x26, 0x84, 0xbd, 0x16, 0x19, 0x67, 0x41, 0x30, 0xce, 0x90, 0x7a, 0x24, 0x9d, 0x0f, 0xd8, 0xda,
+	0x20, 0xd0, 0xda, 0x18, 0xba, 0xf2, 0xd6, 0xec, 0x1c, 0xdb, 0xf3, 0xe8, 0x2b, 0xe2, 0xc8, 0x34,
+	0x49, 0x49, 0xf4, 0x04, 0x2a, 0x61, 0xe2, 0x91, 0x48, 0x76, 0x4d, 0x39, 0x47, 0x48, 0xfb, 0xb0,
+	0xd8, 0xcb, 0x4d, 0x0c, 0xe5, 0xfc, 0xc4, 0x60, 0xfc, 0xa7, 0x04, 0x60, 0xd1, 0xeb, 0x34, 0x18,
+	0x8f, 0xa0, 0x91, 0x3a, 0x2f, 0xf4, 0x35, 0x70, 0x06, 0xb0, 0xd4, 0x96, 0xed, 0x2a, 0x75, 0x59,
+	0x28, 0x6b, 0x0b, 0xf4, 0x5c, 0x3a, 0xfe, 0x10, 0x2a, 0xec, 0x29, 0x46, 0xbc, 0x95, 0x36, 0xb0,
+	0x20, 0xd0, 0x67, 0x50, 0x97, 0x23, 0x4d, 0xc4, 0x1b, 0xe4, 0x9d, 0x89, 0x67, 0xb3, 0xcd, 0x52,
+	0x6b, 0x91, 0x5c, 0x5d, 0x91, 0x70, 0x1e, 0xb9, 0xaf, 0xd3, 0xc9, 0x07, 0x04, 0x74, 0xe1, 0xbe,
+	0x26, 0xe8, 0x08, 0xea, 0xf4, 0x96, 0x84, 0x57, 0x1e, 0x7d, 0xc5, 0x13, 0xa8, 0x73, 0xfc, 0x50,
+	0xea, 0x92, 0xe8, 0x39, 0xf5, 0xdc, 0xe5, 0x1a, 0x6f, 0xb8, 0x98, 0xca, 0x90, 0xac, 0x3c, 0x7b,
+	0x3d, 0xf7, 0xec, 0x48, 0x64, 0x54, 0x1b, 0x83, 0x80, 0x2c, 0x3b, 0x8a, 0x59, 0x5e, 0x4a, 0x86,
+	0xc8, 0x0d, 0x96, 0xa2, 0x95, 0x95, 0xb1, 0x14, 0xba, 0x60, 0x90, 0x60, 0x61, 0xa1, 0x98, 0xdb,
+	0x57, 0x31, 0x09, 0x79, 0x17, 0x53, 0x71, 0x53, 0x60, 0x03, 0x06, 0x7d, 0xa3, 0xd6, 0x15, 0xad,
+	0xf4, 0x8d, 0x5a, 0x6f, 0x6a, 0x2d, 0xe3, 0xaf, 0x0a, 0x68, 0xdb, 0x2d, 0x81, 0xdd, 0xc3, 0x82,
+	0x26, 0xa2, 0x94, 0x95, 0xfb, 0x0a, 0x96, 0x14, 0xc3, 0x97, 0xbc, 0x24, 0xf2, 0xa8, 0xab, 0x58,
+	0x52, 0x2c, 0x96, 0x7c, 0xc5, 0x23, 0xad, 0x62, 0x41, 0xf0, 0x69, 0x2e, 0xf1, 0x79, 0xbe, 0x28,
+	0x98, 0x2d, 0x19, 0xb2, 0x7a, 0x76, 0xc4, 0x5f, 0xa1, 0x82, 0xd9, 0x92, 0x23, 0x27, 0x47, 0x7a,
+	0x55, 0x22, 0x27, 0x12, 0x39, 0xd1, 0x6b, 0x29, 0x72, 0x62, 0xfc, 0x19, 0x20, 0x6b, 0x10, 0xe8,
+	0x59, 0x36, 0xc1, 0x28, 0xf9, 0x49, 0x24, 0x63, 0xd9, 0x35, 0xc7, 0x7c, 0x40, 0x6b, 0x33, 0xfe,
+	0xa2, 0x40, 0x2b, 0xdf, 0x0f, 0xd0, 0x97, 0xf9, 0x29, 0x50, 0xc9, 0x4f, 0x25, 0x79, 0xb6, 0x37,
+	0x4d, 0x83, 0x1f, 0x34, 0x3d, 0x18, 0xff, 0x90, 0x0d, 0x28, 0xda, 0xf9, 0x18, 0x94, 0xed, 0xc7,
+	0xa0, 0x43, 0x4d, 0xd8, 0x99, 0x3e, 0x94, 0x94, 0xdc, 0xd1, 0x01, 0xca, 0x3b, 0x3a, 0x40, 0xbe,
+	0x69, 0xa9, 0xf7, 0x68, 0x5a, 0x95, 0xdd, 0x4d, 0xcb, 0xf8, 0x03, 0x80, 0xb4, 0x9a, 0x95, 0x89,
+	0xe2, 0x3c, 0xa6, 0xdc, 0x73, 0x1e, 0x2b, 0xbd, 0x69, 0x1e, 0x33, 0xae, 0x01, 0x66, 0x74, 0x95,
+	0x46, 0xa4, 0x05, 0x8a, 0x28, 0x73, 0x6d, 0xac, 0x04, 0xe8, 0xd7, 0x50, 0xa1, 0xa1, 0x23, 0xbf,
+	0x83, 0x36, 0xed, 0x70, 0x46, 0x57, 0x53, 0x86, 0x62, 0xb1, 0x79, 0xcf, 0x68, 0x18, 0x57, 0x50,
+	0x9f, 0xd1, 0x95, 0x99, 0xd6, 0xc3, 0xc0, 0xf6, 0x89, 0xbc, 0x36, 0xbe, 0xe6, 0x0f, 0xc1, 0xf6,
+	0xbc, 0x28, 0xbd, 0x37, 0x4e, 0xb0, 0x67, 0x43, 0xc2, 0x90, 0x86, 0x91, 0x7c, 0x1f, 0x92, 0x62,
+	0x97, 0x23, 0xe7, 0x73, 0xf9, 0x48, 0x52, 0xd2, 0xf8, 0xbb, 0x02, 0x0d, 0xee, 0x11, 0x6f, 0x1b,
+	0x6f, 0xff, 0x26, 0x79, 0xba, 0x5d, 0x0d, 0x9b, 0x39, 0x27, 0x45, 0xee, 0x65, 0x0c, 0xa8, 0x9f,
+	0x25, 0x44, 0x79, 0x27, 0x6f, 0xba, 0xcd, 0x02, 0x97, 0x15, 0xc8, 0xbb, 0x7c, 0x62, 0xd3, 0xf8,
+	0x97, 0x22, 0x6e, 0x76, 0x46, 0x63, 0xdb, 0x63, 0xb3, 0x47, 0x35, 0xe6, 0xab, 0x1d, 0xb7, 0x2a,
+	0x77, 0xd0, 0xa0, 0xf8, 0x35, 0x54, 0xda, 0x1e, 0xeb, 0x85, 0xaa, 0xb7, 0x7d, 0x13, 0x75, 0xc7,
+	0xf7, 0x99, 0xa1, 0x7b, 0xc5, 0xc9, 0x2e, 0x6f, 0x4a, 0xf6, 0xa0, 0x0e, 0xe6, 0x50, 0x93, 0xb5,
+	0x1d, 0x35, 0xa1, 0x36, 0xb0, 0xac, 0xe9, 0x4b, 0x73, 0xa4, 0xed, 0x21, 0x80, 0xea, 0xc8, 0x9c,
+	0x8c, 0xcd, 0x91, 0xa6, 0xa0, 0x9f, 0xc1, 0xfe, 0xe5, 0x64, 0x70, 0x39, 0x7b, 0x61, 0x4e, 0x66,
+	0xe3, 0xe1, 0x60, 0x66, 0x8e, 0xb4, 0x12, 0x63, 0xf8, 0x6a, 0x30, 0xb6, 0xcc, 0x91, 0x56, 0x66,
+	0x92, 0xd6, 0xf8, 0x6c, 0xcc, 0x36, 0x54, 0xd4, 0x86, 0xc6, 0x70, 0x7a, 0x76, 0x6e, 0x99, 0x8c,
+	0xac, 0x1c, 0x7c, 0x07, 0x9d, 0x62, 0xc1, 0x47, 0x08, 0x3a, 0xe7, 0x53, 0x6b, 0x3c, 0xfc, 0x7e,
+	0x3e, 0x32, 0xbf, 0x1a, 0x5c, 0x5a, 0x33, 0x6d, 0x0f, 0xed, 0x43, 0x73, 0x84, 0xa7, 0xe7, 0xf3,
+	0xa9, 0x35, 0x32, 0x2f, 0x66, 0x9a, 0xb2, 0x01, 0x26, 0xe6, 0x4b, 0x06, 0x94, 0x50, 0x07, 0x60,
+	0x34, 0xbe, 0x18, 0x4e, 0x27, 0x13, 0x73, 0x38, 0xd3, 0xca, 0x07, 0x9f, 0x43, 0x3d, 0x1d, 0xea,
+	0x50, 0x03, 0x2a, 0x23, 0xd3, 0x9a, 0x0d, 0xb4, 0x3d, 0xc6, 0x36, 0xbc, 0x3c, 0xbb, 0xb4, 0x06,
+	0xb3, 0xf1, 0x77, 0xa6, 0xa6, 0xa0, 0x3a, 0xa8, 0xa7, 0xd3, 0xd9, 0x0b, 0xad, 0x74, 0x70, 0x08,
+	0xf5, 0x34, 0xed, 0x99, 0xc0, 0x70, 0x60, 0x59, 0x17, 0xc2, 0x51, 0x13, 0xe3, 0x29, 0xbe, 0xd0,
+	0x14, 0xee, 0xc7, 0x60, 0x66, 0x4e, 0x86, 0xdf, 0x6b, 0xa5, 0xe3, 0x7f, 0x96, 0xa0, 0x32, 0x70,
+	0x7c, 0x37, 0x40, 0x4f, 0xa1, 0x66, 0xd1, 0xeb, 0x6b, 0x36, 0x06, 0xca, 0x99, 0x25, 0xeb, 0xc6,
+	0xdd, 0xa6, 0x40, 0xf8, 0x6f, 0x09, 0x63, 0xef, 0x48, 0x41, 0x47, 0x22, 0x23, 0xdc, 0x28, 0x76,
+	0x97, 0x11, 0x42, 0x59, 0xd8, 0xd3, 0xa1, 0xb9, 0x9b, 0xbb, 0x0a, 0x2e, 0xf1, 0x05, 0xd4, 0xbf,
+	0x26, 0x31, 0x9f, 0x83, 0x52, 0xfe, 0xfc, 0x54, 0xd9, 0x7d, 0x50, 0xc0, 0xd8, 0x9b, 0x30, 0xf6,
+	0x58, 0x23, 0x90, 0xb3, 0x07, 0x92, 0x7d, 0xb5, 0x38, 0x33, 0x75, 0xd1, 0x16, 0xba, 0xf2, 0xd6,
+	0xc6, 0x1e, 0x3a, 0xe6, 0x67, 0xf1, 0x62, 0x94, 0xb7, 0x2d, 0xad, 0xa7, 0x5d, 0xad, 0x80, 0x09,
+	0x99, 0xdf, 0x40, 0xf5, 0x6b, 0x12, 0xcf, 0xe8, 0x2a, 0x75, 0x3f, 0xab, 0x36, 0xdd, 0xfd, 0x1c,
+	0x22, 0x2c, 0x3b, 0xfe, 0x13, 0x94, 0x4f, 0xdd, 0xd7, 0xe8, 0x53, 0xa8, 0x0c, 0x6f, 0xc8, 0xf2,
+	0x47, 0x24, 0x47, 0x08, 0x39, 0x49, 0x77, 0x8b, 0xa4, 0xb1, 0x87, 0x3e, 0x86, 0xf2, 0xc0, 0x71,
+	0xde, 0xc9, 0xf6, 0x09, 0xa8, 0x33, 0x56, 0xdf, 0xde, 0xc1, 0x77, 0x5a, 0xff, 0xa1, 0x7a, 0xf8,
+	0x9c, 0x61, 0x8b, 0x2a, 0xff, 0x77, 0xf4, 0xc5, 0xff, 0x07, 0x00, 0x7b, 0x69, 0x2d, 0xc7, 0x4c,
+	0x12, 0x00, 0x00,
 }
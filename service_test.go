@@ -157,7 +157,7 @@ func TestACL(t *testing.T) {
 	}
 
 	// ACL на методах, которые возвращают поток данных
-	logger, err := adm.Logging(getConsumerCtx("unknown"), &Nothing{})
+	logger, err := adm.Logging(getConsumerCtx("unknown"), &LogRequest{})
 	_, err = logger.Recv()
 	if err == nil {
 		t.Fatalf("ACL fail: expected err on disallowed method")
@@ -184,10 +184,10 @@ func TestLogging(t *testing.T) {
 	biz := NewBizClient(conn)
 	adm := NewAdminClient(conn)
 
-	logStream1, err := adm.Logging(getConsumerCtx("logger"), &Nothing{})
+	logStream1, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{})
 	time.Sleep(1 * time.Millisecond)
 
-	logStream2, err := adm.Logging(getConsumerCtx("logger"), &Nothing{})
+	logStream2, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{})
 
 	logData1 := []*Event{}
 	logData2 := []*Event{}
@@ -413,6 +413,446 @@ func TestStat(t *testing.T) {
 	finish()
 }
 
+// rejected calls are reported to Logging subscribers with an outcome
+func TestAuditEvents(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+		MethodRateLimits: map[string]map[string]RateLimit{
+			"biz_admin": {"/main.Biz/Add": {Rate: 0.1, Burst: 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	logStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+
+	biz.Test(context.Background(), &Nothing{})
+	biz.Test(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_admin"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_admin"), &Nothing{})
+
+	expected := []*Event{
+		{Consumer: "", Method: "/main.Biz/Test", Outcome: Outcome_UNAUTHENTICATED, Code: uint32(codes.Unauthenticated)},
+		{Consumer: "biz_user", Method: "/main.Biz/Test", Outcome: Outcome_DENIED, Code: uint32(codes.Unauthenticated)},
+		{Consumer: "biz_admin", Method: "/main.Biz/Add", Outcome: Outcome_ALLOWED},
+		{Consumer: "biz_admin", Method: "/main.Biz/Add", Outcome: Outcome_LIMITED, Code: uint32(codes.ResourceExhausted)},
+	}
+	for idx, want := range expected {
+		evt, err := logStream.Recv()
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v, awaiting event", idx, err)
+		}
+		have := &Event{Consumer: evt.Consumer, Method: evt.Method, Outcome: evt.Outcome, Code: evt.Code}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("[%d] events dont match\nhave %+v\nwant %+v", idx, have, want)
+		}
+		if want.Outcome != Outcome_ALLOWED && evt.Reason == "" {
+			t.Fatalf("[%d] expected a reason of a rejected call", idx)
+		}
+	}
+}
+
+// Logging subscribers get only events which pass their filters
+func TestLoggingFilter(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	err := StartMyMicroservice(ctx, listenAddr, ACLData)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	adminStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{Consumers: []string{"biz_admin"}, Hosts: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	deniedStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{MethodPattern: "/main.Biz/*", Outcomes: []Outcome{Outcome_DENIED}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Check(getConsumerCtx("biz_admin"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("unknown"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_admin"), &Nothing{})
+
+	for name, tc := range map[string]struct {
+		stream   Admin_LoggingClient
+		expected []*Event
+	}{
+		"admin": {adminStream, []*Event{
+			{Consumer: "biz_admin", Method: "/main.Biz/Check"},
+			{Consumer: "biz_admin", Method: "/main.Biz/Test"},
+		}},
+		"denied": {deniedStream, []*Event{
+			{Consumer: "biz_user", Method: "/main.Biz/Test", Outcome: Outcome_DENIED},
+			{Consumer: "unknown", Method: "/main.Biz/Add", Outcome: Outcome_DENIED},
+		}},
+	} {
+		for idx, want := range tc.expected {
+			evt, err := tc.stream.Recv()
+			if err != nil {
+				t.Fatalf("[%s %d] unexpected error: %v, awaiting event", name, idx, err)
+			}
+			have := &Event{Consumer: evt.Consumer, Method: evt.Method, Outcome: evt.Outcome}
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("[%s %d] events dont match\nhave %+v\nwant %+v", name, idx, have, want)
+			}
+		}
+	}
+
+	badStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{MethodPattern: "/main.Biz/[Check"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := badStream.Recv(); grpc.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument on bad pattern, got %v", err)
+	}
+}
+
+// events about completed calls carry their duration, status and sizes and are sent only on request
+func TestCompletionEvents(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	err := StartMyMicroservice(ctx, listenAddr, ACLData)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	completed, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{Outcomes: []Outcome{Outcome_COMPLETED}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	streamCtx, cancelStream := context.WithCancel(getConsumerCtx("logger"))
+	defer cancelStream()
+	userStream, err := adm.Logging(streamCtx, &LogRequest{Consumers: []string{"biz_user"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+
+	if _, err := biz.Check(getConsumerCtx("biz_user"), &Nothing{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	evt, err := userStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evt.Outcome != Outcome_ALLOWED {
+		t.Fatalf("expected no completion events without asking, have %v", evt)
+	}
+
+	evt, err = completed.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evt.Consumer != "biz_user" || evt.Method != "/main.Biz/Check" || codes.Code(evt.Code) != codes.OK || evt.Duration <= 0 {
+		t.Fatalf("bad completion of Check: %v", evt)
+	}
+
+	cancelStream()
+	evt, err = completed.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evt.Method != "/main.Admin/Logging" || codes.Code(evt.Code) != codes.Canceled || evt.RequestBytes == 0 || evt.ResponseBytes == 0 {
+		t.Fatalf("bad completion of Logging: %v", evt)
+	}
+}
+
+// finished calls are counted by status code, rejected ones too
+func TestStatCodes(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	err := StartMyMicroservice(ctx, listenAddr, ACLData)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	statStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalSeconds: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("unknown"), &Nothing{})
+
+	stat, err := statStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedByCode := map[string]uint64{"OK": 1, "Unauthenticated": 2}
+	if !reflect.DeepEqual(stat.ByCode, expectedByCode) {
+		t.Fatalf("by code dont match\nhave %+v\nwant %+v", stat.ByCode, expectedByCode)
+	}
+	expectedByMethodCode := map[string]map[string]uint64{
+		"/main.Biz/Check": {"OK": 1},
+		"/main.Biz/Test":  {"Unauthenticated": 1},
+		"/main.Biz/Add":   {"Unauthenticated": 1},
+	}
+	byMethodCode := map[string]map[string]uint64{}
+	for method, counts := range stat.ByMethodCode {
+		byMethodCode[method] = counts.ByCode
+	}
+	if !reflect.DeepEqual(byMethodCode, expectedByMethodCode) {
+		t.Fatalf("by method and code dont match\nhave %+v\nwant %+v", byMethodCode, expectedByMethodCode)
+	}
+}
+
+// breakdowns by host and by consumer and method are sent only to clients which asked for them
+func TestStatBreakdowns(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	err := StartMyMicroservice(ctx, listenAddr, ACLData)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	plainStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalSeconds: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	fullStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalSeconds: 1, ByHost: true, ByConsumerMethod: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_admin"), &Nothing{})
+
+	stat, err := plainStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stat.ByHost != nil || stat.ByConsumerMethod != nil {
+		t.Fatalf("expected no breakdowns, have %v and %v", stat.ByHost, stat.ByConsumerMethod)
+	}
+
+	stat, err = fullStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedByHost := map[string]uint64{"127.0.0.1": 3}
+	if !reflect.DeepEqual(stat.ByHost, expectedByHost) {
+		t.Fatalf("by host dont match\nhave %+v\nwant %+v", stat.ByHost, expectedByHost)
+	}
+	expectedByConsumerMethod := map[string]map[string]uint64{
+		"biz_user":  {"/main.Biz/Check": 1, "/main.Biz/Add": 1},
+		"biz_admin": {"/main.Biz/Test": 1},
+	}
+	byConsumerMethod := map[string]map[string]uint64{}
+	for consumer, counts := range stat.ByConsumerMethod {
+		byConsumerMethod[consumer] = counts.ByMethod
+	}
+	if !reflect.DeepEqual(byConsumerMethod, expectedByConsumerMethod) {
+		t.Fatalf("by consumer and method dont match\nhave %+v\nwant %+v", byConsumerMethod, expectedByConsumerMethod)
+	}
+}
+
+// statistics come at millisecond intervals as deltas, totals since the start or both
+func TestStatModes(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	err := StartMyMicroservice(ctx, listenAddr, ACLData)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	for idx, interval := range []*StatInterval{
+		{},
+		{IntervalMs: 1},
+		{IntervalSeconds: 1, Mode: StatMode(42)},
+	} {
+		badStream, err := adm.Statistics(getConsumerCtx("stat"), interval)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v", idx, err)
+		}
+		if _, err := badStream.Recv(); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("[%d] expected InvalidArgument, got %v", idx, err)
+		}
+	}
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+
+	bothStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalMs: 20, Mode: StatMode_BOTH})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cumulativeStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalMs: 20, Mode: StatMode_CUMULATIVE})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+
+	var checks uint64
+	for i := 0; i < 50; i++ {
+		stat, err := bothStream.Recv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stat.Cumulative == nil {
+			t.Fatalf("expected totals along with deltas, have %v", stat)
+		}
+		checks += stat.ByMethod["/main.Biz/Check"]
+		if stat.Cumulative.ByMethod["/main.Biz/Check"] == 3 {
+			break
+		}
+	}
+	if checks != 1 {
+		t.Fatalf("expected 1 Check in deltas, have %d", checks)
+	}
+
+	stat, err := cumulativeStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stat.Cumulative != nil || stat.ByMethod["/main.Biz/Check"] < 2 || stat.ByMethod["/main.Admin/Statistics"] < 2 {
+		t.Fatalf("expected totals instead of deltas, have %v", stat)
+	}
+}
+
+// admin streams are cleaned up on disconnect and stopped together with the server
+func TestAdminStreamsCleanup(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	msCtx, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	adm := NewAdminClient(conn)
+
+	subscribers := func() (int, int) {
+		msCtx.Lock.Lock()
+		defer msCtx.Lock.Unlock()
+		return len(msCtx.Loggers), len(msCtx.StatData)
+	}
+
+	streamCtx, cancelStreams := context.WithCancel(getConsumerCtx("logger"))
+	adm.Logging(streamCtx, &LogRequest{})
+	adm.Logging(streamCtx, &LogRequest{})
+	wait(1)
+	if loggers, _ := subscribers(); loggers != 2 {
+		t.Fatalf("expected 2 loggers, have %d", loggers)
+	}
+	cancelStreams()
+	wait(1)
+	if loggers, _ := subscribers(); loggers != 0 {
+		t.Fatalf("expected loggers to be removed on disconnect, have %d", loggers)
+	}
+
+	logStream, err := adm.Logging(getConsumerCtx("logger"), &LogRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	statStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalSeconds: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait(1)
+	if loggers, stats := subscribers(); loggers != 1 || stats != 1 {
+		t.Fatalf("expected a logger and a statistics client, have %d and %d", loggers, stats)
+	}
+
+	start := time.Now()
+	finish()
+	// the logger may get the event of the Statistics call before the stream is stopped
+	for err == nil {
+		_, err = logStream.Recv()
+	}
+	if grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable on the logging stream, got %v", err)
+	}
+	if _, err := statStream.Recv(); grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable on the statistics stream, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("admin streams are stopped too slowly: %v", elapsed)
+	}
+	wait(1)
+	if loggers, stats := subscribers(); loggers != 0 || stats != 0 {
+		t.Fatalf("expected no subscribers after stop, have %d and %d", loggers, stats)
+	}
+}
+
 func __dummyLog() {
 	fmt.Println(1)
 	log.Println(1)
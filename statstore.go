@@ -0,0 +1,518 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//defaultStatWindow is how long counts of recent calls are kept by default
+const defaultStatWindow = 5 * time.Minute
+
+//statBucket holds counts of calls made within one second
+type statBucket struct {
+	second    int64
+	stat      Stat
+	consumers map[string]Stat //the same counts by consumer
+	top       map[topKey]*topCounts
+}
+
+//statStore counts every call once for all statistics clients:
+//totals since the start and one second buckets over a window.
+//The cost of a call does not depend on how many clients there are.
+//If it has a path, the totals are loaded from the file and flushed back to it
+type statStore struct {
+	lock        *sync.Mutex
+	bounds      []float64 //upper bounds of latency buckets in seconds
+	totals      Stat
+	consumers   map[string]Stat              //the totals by consumer, to report the calls of some consumers only
+	consumerKey func(consumer string) string //the key of a consumer in consumers, nil keys consumers by name
+	buckets     []statBucket
+	flushLock   *sync.Mutex //keeps flushes from writing the file at the same time
+	path        string
+	dirty       bool
+}
+
+//newStatStore helps to make a statStore keeping recent calls for the window
+func newStatStore(window time.Duration, bounds []float64) *statStore {
+	if window <= 0 {
+		window = defaultStatWindow
+	}
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &statStore{
+		lock:      &sync.Mutex{},
+		bounds:    bounds,
+		totals:    fullStat(),
+		consumers: make(map[string]Stat),
+		buckets:   make([]statBucket, seconds),
+		flushLock: &sync.Mutex{},
+	}
+}
+
+//window returns how long counts of recent calls are kept
+func (s *statStore) window() time.Duration {
+	return time.Duration(len(s.buckets)) * time.Second
+}
+
+//windowOf validates a window asked in a request, zero asks for the whole window kept
+func (s *statStore) windowOf(seconds uint32) (time.Duration, error) {
+	if seconds == 0 {
+		return s.window(), nil
+	}
+	window := time.Duration(seconds) * time.Second
+	if window > s.window() {
+		return 0, status.Errorf(codes.InvalidArgument, "window %v is longer than %v", window, s.window())
+	}
+	return window, nil
+}
+
+//fullStat makes an empty Stat with every breakdown
+func fullStat() Stat {
+	return newStat(&StatInterval{ByHost: true, ByConsumerMethod: true})
+}
+
+//bucket returns the bucket of the second of now, the bucket is cleared if it holds an older second
+func (s *statStore) bucket(now time.Time) *statBucket {
+	second := now.Unix()
+	bucket := &s.buckets[second%int64(len(s.buckets))]
+	if bucket.second != second || bucket.stat.ByMethod == nil {
+		bucket.second = second
+		bucket.stat = fullStat()
+		bucket.consumers = make(map[string]Stat)
+		bucket.top = make(map[topKey]*topCounts)
+	}
+	return bucket
+}
+
+//consumerStat returns the Stat of a consumer making it if there is none
+func consumerStat(stats map[string]Stat, consumer string) Stat {
+	stat, found := stats[consumer]
+	if !found {
+		stat = fullStat()
+		stats[consumer] = stat
+	}
+	return stat
+}
+
+//count applies count to the totals and to the bucket of now, both to all calls and to the calls of the consumer.
+//Calls without a consumer are counted in all calls only
+func (s *statStore) count(consumer string, now time.Time, count func(Stat)) *statBucket {
+	bucket := s.bucket(now)
+	count(s.totals)
+	count(bucket.stat)
+	if consumer != "" {
+		key := consumer
+		if s.consumerKey != nil {
+			key = s.consumerKey(consumer)
+		}
+		count(consumerStat(s.consumers, key))
+		count(consumerStat(bucket.consumers, key))
+	}
+	s.dirty = true
+	return bucket
+}
+
+//addUsage counts a call of a consumer from a host
+func (s *statStore) addUsage(consumer string, method string, host string, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bucket := s.count(consumer, now, func(stat Stat) { countUsage(stat, consumer, method, host) })
+	countTop(bucket.top, consumer, method, host, func(counts *topCounts) { counts.calls++ })
+}
+
+//addCode counts the status of a finished call
+func (s *statStore) addCode(consumer string, method string, host string, code string, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bucket := s.count(consumer, now, func(stat Stat) { countCode(stat, method, code) })
+	if code != codes.OK.String() {
+		countTop(bucket.top, consumer, method, host, func(counts *topCounts) { counts.errors++ })
+	}
+}
+
+//addLatency counts the duration of a handled call
+func (s *statStore) addLatency(consumer string, method string, host string, duration time.Duration, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bucket := s.count(consumer, now, func(stat Stat) { countLatency(stat, method, duration, s.bounds) })
+	countTop(bucket.top, consumer, method, host, func(counts *topCounts) { counts.latency += duration.Seconds() })
+}
+
+//load restores the totals and the totals by consumer from a file written by flush, there is nothing to restore if the file does not exist.
+//Latency histograms with other bounds are dropped since they can't be counted further
+func (s *statStore) load(path string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.path = path
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := &StatTotals{}
+	if err := proto.Unmarshal(data, saved); err != nil {
+		return err
+	}
+	s.restore(s.totals, saved.Totals)
+	for consumer, stat := range saved.ByConsumer {
+		s.restore(consumerStat(s.consumers, consumer), stat)
+	}
+	return nil
+}
+
+//restore adds saved counts to a Stat dropping latency histograms with other bounds
+func (s *statStore) restore(dst Stat, saved *Stat) {
+	if saved == nil {
+		return
+	}
+	for method, histogram := range saved.LatencyByMethod {
+		if !reflect.DeepEqual(histogram.Bounds, s.bounds) {
+			log.Println("dropping saved latencies of", method, "with other buckets")
+			delete(saved.LatencyByMethod, method)
+		}
+	}
+	mergeStat(dst, *saved)
+}
+
+//flush writes the totals and the totals by consumer to the file if they have changed.
+//The totals stay changed if they can't be written, so the next flush retries
+func (s *statStore) flush() error {
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	s.lock.Lock()
+	if s.path == "" || !s.dirty {
+		s.lock.Unlock()
+		return nil
+	}
+	saved := &StatTotals{Totals: &s.totals, ByConsumer: make(map[string]*Stat, len(s.consumers))}
+	for consumer := range s.consumers {
+		stat := s.consumers[consumer]
+		saved.ByConsumer[consumer] = &stat
+	}
+	data, err := proto.Marshal(saved)
+	s.dirty = false
+	s.lock.Unlock()
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+	if err != nil {
+		s.lock.Lock()
+		s.dirty = true
+		s.lock.Unlock()
+	}
+	return err
+}
+
+//snapshot returns a copy of the totals
+func (s *statStore) snapshot() *Stat {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return proto.Clone(&s.totals).(*Stat)
+}
+
+//snapshotFor returns a copy of the totals with only the breakdowns a statistics client asked for
+func (s *statStore) snapshotFor(interval *StatInterval) *Stat {
+	result := newStat(interval)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	mergeStat(result, s.totals)
+	return &result
+}
+
+//snapshotOf returns the totals of the calls of the consumers, of all calls if there are no consumers
+func (s *statStore) snapshotOf(consumers []string) *Stat {
+	if len(consumers) == 0 {
+		return s.snapshot()
+	}
+	result := fullStat()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for consumer := range stringSet(consumers) {
+		if stat, found := s.consumers[consumer]; found {
+			mergeStat(result, stat)
+		}
+	}
+	return &result
+}
+
+//recent returns the counts of calls made within the last window up to now,
+//the window is rounded up to whole seconds and cut to the window of the store
+func (s *statStore) recent(window time.Duration, now time.Time) *Stat {
+	return s.recentOf(window, now, nil)
+}
+
+//recentOf returns the counts of calls of the consumers made within the last window up to now,
+//of all calls if there are no consumers
+func (s *statStore) recentOf(window time.Duration, now time.Time, consumers []string) *Stat {
+	seconds := int64((window + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	last := now.Unix()
+	first := last - seconds + 1
+	result := fullStat()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	consumerSet := stringSet(consumers)
+	for _, bucket := range s.buckets {
+		if bucket.stat.ByMethod == nil || bucket.second < first || bucket.second > last {
+			continue
+		}
+		if consumerSet == nil {
+			mergeStat(result, bucket.stat)
+			continue
+		}
+		for consumer := range consumerSet {
+			if stat, found := bucket.consumers[consumer]; found {
+				mergeStat(result, stat)
+			}
+		}
+	}
+	return &result
+}
+
+//newStat makes an empty Stat to count calls in.
+//Breakdowns by host and by consumer and method are counted only if the client asked for them
+func newStat(interval *StatInterval) Stat {
+	stat := Stat{
+		ByConsumer:      make(map[string]uint64),
+		ByMethod:        make(map[string]uint64),
+		LatencyByMethod: make(map[string]*LatencyHistogram),
+		ByCode:          make(map[string]uint64),
+		ByMethodCode:    make(map[string]*CodeCounts),
+	}
+	if interval.ByHost {
+		stat.ByHost = make(map[string]uint64)
+	}
+	if interval.ByConsumerMethod {
+		stat.ByConsumerMethod = make(map[string]*MethodCounts)
+	}
+	return stat
+}
+
+//countUsage counts a call in a Stat
+func countUsage(stat Stat, consumer string, method string, host string) {
+	stat.ByMethod[method]++
+	if consumer != "" {
+		stat.ByConsumer[consumer]++
+	}
+	if stat.ByHost != nil {
+		stat.ByHost[host]++
+	}
+	if stat.ByConsumerMethod != nil && consumer != "" {
+		counts, found := stat.ByConsumerMethod[consumer]
+		if !found {
+			counts = &MethodCounts{ByMethod: make(map[string]uint64)}
+			stat.ByConsumerMethod[consumer] = counts
+		}
+		counts.ByMethod[method]++
+	}
+}
+
+//countCode counts the status of a call in a Stat
+func countCode(stat Stat, method string, code string) {
+	stat.ByCode[code]++
+	counts, found := stat.ByMethodCode[method]
+	if !found {
+		counts = &CodeCounts{ByCode: make(map[string]uint64)}
+		stat.ByMethodCode[method] = counts
+	}
+	counts.ByCode[code]++
+}
+
+//mergeCounts adds counts of src to dst
+func mergeCounts(dst map[string]uint64, src map[string]uint64) {
+	for key, count := range src {
+		dst[key] += count
+	}
+}
+
+//mergeStat adds counts of src to dst, the optional breakdowns dst has not got are skipped
+func mergeStat(dst Stat, src Stat) {
+	mergeCounts(dst.ByMethod, src.ByMethod)
+	mergeCounts(dst.ByConsumer, src.ByConsumer)
+	mergeCounts(dst.ByCode, src.ByCode)
+	if dst.ByHost != nil {
+		mergeCounts(dst.ByHost, src.ByHost)
+	}
+	for method, counts := range src.ByMethodCode {
+		if dst.ByMethodCode[method] == nil {
+			dst.ByMethodCode[method] = &CodeCounts{ByCode: make(map[string]uint64)}
+		}
+		mergeCounts(dst.ByMethodCode[method].ByCode, counts.ByCode)
+	}
+	for consumer, counts := range src.ByConsumerMethod {
+		if dst.ByConsumerMethod == nil {
+			break
+		}
+		if dst.ByConsumerMethod[consumer] == nil {
+			dst.ByConsumerMethod[consumer] = &MethodCounts{ByMethod: make(map[string]uint64)}
+		}
+		mergeCounts(dst.ByConsumerMethod[consumer].ByMethod, counts.ByMethod)
+	}
+	for method, histogram := range src.LatencyByMethod {
+		if dst.LatencyByMethod[method] == nil {
+			dst.LatencyByMethod[method] = newLatencyHistogram(histogram.Bounds)
+		}
+		merged := dst.LatencyByMethod[method]
+		for i, count := range histogram.Counts {
+			merged.Counts[i] += count
+		}
+		merged.Count += histogram.Count
+		merged.Sum += histogram.Sum
+	}
+}
+
+//diffCounts returns counts which have grown from prev to cur, nil if none has
+func diffCounts(cur map[string]uint64, prev map[string]uint64) map[string]uint64 {
+	var result map[string]uint64
+	for key, count := range cur {
+		if count == prev[key] {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]uint64)
+		}
+		result[key] = count - prev[key]
+	}
+	return result
+}
+
+//diffStat returns the counts of calls made between two snapshots of the totals
+func diffStat(cur *Stat, prev *Stat) Stat {
+	result := fullStat()
+	mergeCounts(result.ByMethod, diffCounts(cur.ByMethod, prev.ByMethod))
+	mergeCounts(result.ByConsumer, diffCounts(cur.ByConsumer, prev.ByConsumer))
+	mergeCounts(result.ByCode, diffCounts(cur.ByCode, prev.ByCode))
+	mergeCounts(result.ByHost, diffCounts(cur.ByHost, prev.ByHost))
+	for method, counts := range cur.ByMethodCode {
+		if diff := diffCounts(counts.ByCode, prev.ByMethodCode[method].GetByCode()); diff != nil {
+			result.ByMethodCode[method] = &CodeCounts{ByCode: diff}
+		}
+	}
+	for consumer, counts := range cur.ByConsumerMethod {
+		if diff := diffCounts(counts.ByMethod, prev.ByConsumerMethod[consumer].GetByMethod()); diff != nil {
+			result.ByConsumerMethod[consumer] = &MethodCounts{ByMethod: diff}
+		}
+	}
+	for method, histogram := range cur.LatencyByMethod {
+		before := prev.LatencyByMethod[method]
+		if before != nil && before.Count == histogram.Count {
+			continue
+		}
+		diff := newLatencyHistogram(histogram.Bounds)
+		for i, count := range histogram.Counts {
+			diff.Counts[i] = count
+			if before != nil {
+				diff.Counts[i] -= before.Counts[i]
+			}
+		}
+		diff.Count = histogram.Count - before.GetCount()
+		diff.Sum = histogram.Sum - before.GetSum()
+		result.LatencyByMethod[method] = diff
+	}
+	return result
+}
+
+//selectStat drops breakdowns a client did not ask for and fills percentiles of latencies
+func selectStat(stat *Stat, interval *StatInterval, now time.Time) {
+	stat.Timestamp = now.UnixNano()
+	if !interval.ByHost {
+		stat.ByHost = nil
+	}
+	if !interval.ByConsumerMethod {
+		stat.ByConsumerMethod = nil
+	}
+	for _, histogram := range stat.LatencyByMethod {
+		summarizeLatency(histogram)
+	}
+}
+
+//stringSet makes a set of values, nil if there are none
+func stringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+//filterStat keeps only the methods asked in the breakdowns keyed by them, the counts by consumer and by code
+//are summed over the methods kept. Hosts are not counted by method, so they are not narrowed.
+//An empty filter keeps everything, stat has every breakdown
+func filterStat(stat *Stat, methods []string) {
+	methodSet := stringSet(methods)
+	if methodSet == nil {
+		return
+	}
+	for method := range stat.ByMethod {
+		if !methodSet[method] {
+			delete(stat.ByMethod, method)
+		}
+	}
+	for method := range stat.ByMethodCode {
+		if !methodSet[method] {
+			delete(stat.ByMethodCode, method)
+		}
+	}
+	for method := range stat.LatencyByMethod {
+		if !methodSet[method] {
+			delete(stat.LatencyByMethod, method)
+		}
+	}
+	for consumer, counts := range stat.ByConsumerMethod {
+		for method := range counts.ByMethod {
+			if !methodSet[method] {
+				delete(counts.ByMethod, method)
+			}
+		}
+		if len(counts.ByMethod) == 0 {
+			delete(stat.ByConsumerMethod, consumer)
+		}
+	}
+	stat.ByConsumer = make(map[string]uint64)
+	for consumer, counts := range stat.ByConsumerMethod {
+		for _, count := range counts.ByMethod {
+			stat.ByConsumer[consumer] += count
+		}
+	}
+	stat.ByCode = make(map[string]uint64)
+	for _, counts := range stat.ByMethodCode {
+		mergeCounts(stat.ByCode, counts.ByCode)
+	}
+}
+
+//GetStats is an implementation GetStats function of AdminServer interface.
+//It reports totals since the start and counts of the recent window, the whole window kept if none is asked.
+//Consumers narrow every breakdown to the calls of the consumers, methods narrow the breakdowns keyed by them.
+//Consumers without an entry in the ACL are counted together, they are asked for as "*"
+func (m *MsCtx) GetStats(ctx context.Context, req *StatsRequest) (*StatsReply, error) {
+	window, err := m.stats.windowOf(req.WindowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	interval := &StatInterval{ByHost: req.ByHost, ByConsumerMethod: req.ByConsumerMethod}
+	reply := &StatsReply{Cumulative: m.stats.snapshotOf(req.Consumers), Window: m.stats.recentOf(window, now, req.Consumers)}
+	for _, stat := range []*Stat{reply.Cumulative, reply.Window} {
+		filterStat(stat, req.Methods)
+		selectStat(stat, interval, now)
+	}
+	return reply, nil
+}
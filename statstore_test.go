@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatStoreWindow(t *testing.T) {
+	store := newStatStore(3*time.Second, Config{}.latencyBounds())
+	start := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", now)
+		store.addCode("biz_user", "/main.Biz/Check", "127.0.0.1", "OK", now)
+		store.addLatency("biz_user", "/main.Biz/Check", "127.0.0.1", time.Millisecond, now)
+	}
+	store.addUsage("biz_admin", "/main.Biz/Test", "10.0.0.1", start.Add(4500*time.Millisecond))
+
+	totals := store.snapshot()
+	if totals.ByMethod["/main.Biz/Check"] != 5 || totals.LatencyByMethod["/main.Biz/Check"].Count != 5 {
+		t.Fatalf("bad totals: %v", totals)
+	}
+	for idx, tc := range []struct {
+		window   time.Duration
+		now      time.Time
+		expected map[string]uint64
+	}{
+		{time.Second, start.Add(4 * time.Second), map[string]uint64{"biz_user": 1, "biz_admin": 1}},
+		{2 * time.Second, start.Add(4 * time.Second), map[string]uint64{"biz_user": 2, "biz_admin": 1}},
+		{time.Hour, start.Add(4 * time.Second), map[string]uint64{"biz_user": 3, "biz_admin": 1}},
+		{3 * time.Second, start.Add(6 * time.Second), map[string]uint64{"biz_user": 1, "biz_admin": 1}},
+		{3 * time.Second, start.Add(time.Minute), map[string]uint64{}},
+	} {
+		recent := store.recent(tc.window, tc.now)
+		if !reflect.DeepEqual(recent.ByConsumer, tc.expected) {
+			t.Fatalf("[%d] expected %v, have %v", idx, tc.expected, recent.ByConsumer)
+		}
+	}
+	recent := store.recent(2*time.Second, start.Add(4*time.Second))
+	if recent.ByMethodCode["/main.Biz/Check"].ByCode["OK"] != 2 || recent.LatencyByMethod["/main.Biz/Check"].Count != 2 {
+		t.Fatalf("bad window: %v", recent)
+	}
+}
+
+func TestStatStoreDiff(t *testing.T) {
+	store := newStatStore(0, Config{}.latencyBounds())
+	now := time.Now()
+	store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", now)
+	store.addLatency("biz_user", "/main.Biz/Check", "127.0.0.1", time.Millisecond, now)
+	before := store.snapshot()
+	store.addUsage("biz_admin", "/main.Biz/Test", "127.0.0.1", now)
+	store.addCode("biz_admin", "/main.Biz/Test", "127.0.0.1", "OK", now)
+	store.addLatency("biz_admin", "/main.Biz/Test", "127.0.0.1", time.Second, now)
+
+	diff := diffStat(store.snapshot(), before)
+	if !reflect.DeepEqual(diff.ByMethod, map[string]uint64{"/main.Biz/Test": 1}) ||
+		!reflect.DeepEqual(diff.ByConsumer, map[string]uint64{"biz_admin": 1}) ||
+		!reflect.DeepEqual(diff.ByHost, map[string]uint64{"127.0.0.1": 1}) ||
+		!reflect.DeepEqual(diff.ByConsumerMethod["biz_admin"].ByMethod, map[string]uint64{"/main.Biz/Test": 1}) ||
+		diff.ByMethodCode["/main.Biz/Test"].ByCode["OK"] != 1 {
+		t.Fatalf("bad counts in diff: %v", diff)
+	}
+	if len(diff.LatencyByMethod) != 1 || diff.LatencyByMethod["/main.Biz/Test"].Count != 1 {
+		t.Fatalf("bad latencies in diff: %v", diff.LatencyByMethod)
+	}
+}
+
+func TestStatStoreSnapshotFor(t *testing.T) {
+	store := newStatStore(0, Config{}.latencyBounds())
+	now := time.Now()
+	store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", now)
+	store.addLatency("biz_user", "/main.Biz/Check", "127.0.0.1", time.Millisecond, now)
+
+	// only the breakdowns asked for are copied
+	plain := store.snapshotFor(&StatInterval{})
+	if plain.ByHost != nil || plain.ByConsumerMethod != nil ||
+		plain.ByMethod["/main.Biz/Check"] != 1 || plain.LatencyByMethod["/main.Biz/Check"].Count != 1 {
+		t.Fatalf("bad snapshot without breakdowns: %v", plain)
+	}
+	full := store.snapshotFor(&StatInterval{ByHost: true, ByConsumerMethod: true})
+	if full.ByHost["127.0.0.1"] != 1 || full.ByConsumerMethod["biz_user"].ByMethod["/main.Biz/Check"] != 1 {
+		t.Fatalf("bad snapshot with breakdowns: %v", full)
+	}
+
+	// a snapshot does not share counts with the store
+	store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", now)
+	if full.ByMethod["/main.Biz/Check"] != 1 || full.ByHost["127.0.0.1"] != 1 {
+		t.Fatalf("snapshot changed with the store: %v", full)
+	}
+}
+
+func TestStatWindow(t *testing.T) {
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{StatWindow: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	// calls made before a client connects are in the window
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_user"), &Nothing{})
+
+	badStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalSeconds: 1, WindowSeconds: 11})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := badStream.Recv(); err == nil {
+		t.Fatalf("expected an error on a window longer than kept")
+	}
+
+	statStream, err := adm.Statistics(getConsumerCtx("stat"), &StatInterval{IntervalMs: 100, WindowSeconds: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stat, err := statStream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stat.ByConsumer) != 0 {
+		t.Fatalf("expected no calls in deltas, have %v", stat.ByConsumer)
+	}
+	if stat.Window == nil || stat.Window.ByConsumer["biz_user"] != 2 {
+		t.Fatalf("expected earlier calls in the window, have %v", stat.Window)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	acl := `{
+	"stat":      ["/main.Admin/GetStats"],
+	"biz_user":  ["/main.Biz/Check", "/main.Biz/Add"],
+	"biz_admin": ["/main.Biz/*"]
+}`
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, acl, Config{StatWindow: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_admin"), &Nothing{})
+
+	reply, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{
+		Consumers:        []string{"biz_user"},
+		Methods:          []string{"/main.Biz/Check"},
+		ByConsumerMethod: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, stat := range map[string]*Stat{"cumulative": reply.Cumulative, "window": reply.Window} {
+		if !reflect.DeepEqual(stat.ByConsumer, map[string]uint64{"biz_user": 2}) ||
+			!reflect.DeepEqual(stat.ByMethod, map[string]uint64{"/main.Biz/Check": 2}) ||
+			!reflect.DeepEqual(stat.ByCode, map[string]uint64{"OK": 2}) ||
+			len(stat.ByConsumerMethod) != 1 || stat.ByConsumerMethod["biz_user"].ByMethod["/main.Biz/Check"] != 2 ||
+			stat.ByHost != nil || stat.LatencyByMethod["/main.Biz/Check"].Count != 2 {
+			t.Fatalf("[%s] bad stat: %v", name, stat)
+		}
+	}
+
+	// every breakdown is narrowed to the calls of the consumers
+	user, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{Consumers: []string{"biz_admin"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(user.Cumulative.ByMethod, map[string]uint64{"/main.Biz/Test": 1}) ||
+		len(user.Cumulative.ByMethodCode) != 1 || len(user.Cumulative.LatencyByMethod) != 1 ||
+		!reflect.DeepEqual(user.Window.ByCode, map[string]uint64{"OK": 1}) {
+		t.Fatalf("bad stat of a consumer: %v", user)
+	}
+
+	// consumers without an entry in the ACL are counted together under "*"
+	biz.Check(getConsumerCtx("made_up_1"), &Nothing{})
+	biz.Check(getConsumerCtx("made_up_2"), &Nothing{})
+	others, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{Consumers: []string{"*"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(others.Cumulative.ByConsumer, map[string]uint64{"made_up_1": 1, "made_up_2": 1}) {
+		t.Fatalf("bad stat of other consumers: %v", others.Cumulative)
+	}
+	madeUp, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{Consumers: []string{"made_up_1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(madeUp.Cumulative.ByMethod) != 0 {
+		t.Fatalf("expected no stat of a consumer unknown to the ACL: %v", madeUp.Cumulative)
+	}
+
+	all, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{WindowSeconds: 1, ByHost: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if all.Cumulative.ByConsumer["biz_admin"] != 1 || all.Cumulative.ByHost["127.0.0.1"] < 4 || all.Cumulative.ByConsumerMethod != nil {
+		t.Fatalf("bad unfiltered stat: %v", all.Cumulative)
+	}
+
+	if _, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{WindowSeconds: 11}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument on a window longer than kept, got %v", err)
+	}
+}
+
+func TestStatFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stat")
+	if err != nil {
+		t.Fatalf("cant make a dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	acl := `{
+	"stat":     ["/main.Admin/GetStats"],
+	"biz_user": ["/main.Biz/Check", "/main.Biz/Add"]
+}`
+	cfg := Config{
+		StatFile:          filepath.Join(dir, "stat.pb"),
+		StatFlushInterval: 10 * time.Millisecond,
+	}
+
+	ctx, finish := context.WithCancel(context.Background())
+	_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, acl, cfg)
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	conn := getGrpcConn(t)
+	biz := NewBizClient(conn)
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_user"), &Nothing{})
+	conn.Close()
+	finish()
+	wait(2)
+
+	// the totals survive a restart, recent calls do not
+	ctx, finish = context.WithCancel(context.Background())
+	_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, acl, cfg)
+	if err != nil {
+		t.Fatalf("cant start server again: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn = getGrpcConn(t)
+	defer conn.Close()
+	biz = NewBizClient(conn)
+	adm := NewAdminClient(conn)
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+
+	reply, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{Methods: []string{"/main.Biz/Check", "/main.Biz/Add"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(reply.Cumulative.ByMethod, map[string]uint64{"/main.Biz/Check": 2, "/main.Biz/Add": 1}) ||
+		reply.Cumulative.LatencyByMethod["/main.Biz/Check"].Count != 2 {
+		t.Fatalf("bad restored totals: %v", reply.Cumulative)
+	}
+	if !reflect.DeepEqual(reply.Window.ByMethod, map[string]uint64{"/main.Biz/Check": 1}) {
+		t.Fatalf("bad window after restart: %v", reply.Window)
+	}
+
+	// so do the totals by consumer
+	user, err := adm.GetStats(getConsumerCtx("stat"), &StatsRequest{Consumers: []string{"biz_user"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(user.Cumulative.ByMethod, map[string]uint64{"/main.Biz/Check": 2, "/main.Biz/Add": 1}) {
+		t.Fatalf("bad restored totals of a consumer: %v", user.Cumulative)
+	}
+}
+
+func TestStatFileBounds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stat")
+	if err != nil {
+		t.Fatalf("cant make a dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stat.pb")
+
+	store := newStatStore(0, []float64{1})
+	if err := store.load(path); err != nil {
+		t.Fatalf("unexpected error on a missing file: %v", err)
+	}
+	store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", time.Now())
+	store.addLatency("biz_user", "/main.Biz/Check", "127.0.0.1", time.Millisecond, time.Now())
+	if err := store.flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// histograms with other buckets can't be counted further
+	restored := newStatStore(0, []float64{2})
+	if err := restored.load(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	totals := restored.snapshot()
+	if totals.ByMethod["/main.Biz/Check"] != 1 || len(totals.LatencyByMethod) != 0 {
+		t.Fatalf("bad restored totals: %v", totals)
+	}
+}
+
+func TestStatFileRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stat")
+	if err != nil {
+		t.Fatalf("cant make a dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "missing", "stat.pb")
+
+	store := newStatStore(0, Config{}.latencyBounds())
+	if err := store.load(path); err != nil {
+		t.Fatalf("unexpected error on a missing file: %v", err)
+	}
+	store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", time.Now())
+	if err := store.flush(); err == nil {
+		t.Fatalf("expected an error writing into a missing dir")
+	}
+
+	// the totals are written by the next flush
+	if err := os.Mkdir(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("cant make a dir: %v", err)
+	}
+	if err := store.flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := newStatStore(0, Config{}.latencyBounds())
+	if err := restored.load(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.snapshot().ByMethod["/main.Biz/Check"] != 1 {
+		t.Fatalf("expected the totals to be written, have %v", restored.snapshot())
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+//checkAuthConfig rejects a config in which consumers could not be authenticated as it says.
+//Client certificates need the server to serve TLS, and they can't be mixed with bearer tokens
+func (cfg Config) checkAuthConfig() error {
+	if cfg.TLSClientCAFile == "" {
+		return nil
+	}
+	if cfg.TLSCertFile == "" {
+		return errors.New("tls client ca file is set without a server certificate")
+	}
+	if len(cfg.AuthKeys) != 0 {
+		return errors.New("auth keys can't be used together with client certificates")
+	}
+	return nil
+}
+
+//newServerTLSConfig loads the server certificate and, if it is configured,
+//the CA bundle which client certificates are required to be signed by
+func newServerTLSConfig(cfg Config) (*tls.Config, error) {
+	switch cfg.TLSIdentity {
+	case "", "cn", "san":
+	default:
+		return nil, fmt.Errorf("unknown tls identity '%s'", cfg.TLSIdentity)
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLSClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+//consumerFromPeer returns a consumer from the verified client certificate of a call
+func (m *MsCtx) consumerFromPeer(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("no peer")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", errors.New("not a tls connection")
+	}
+	if len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", errors.New("no verified client certificate")
+	}
+	consumer := certConsumer(tlsInfo.State.VerifiedChains[0][0], m.cfg.TLSIdentity)
+	if consumer == "" {
+		return "", fmt.Errorf("no consumer in the client certificate")
+	}
+	return consumer, nil
+}
+
+//certConsumer returns the part of a certificate which holds a consumer name
+func certConsumer(cert *x509.Certificate, identity string) string {
+	if identity != "san" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) != 0 {
+		return cert.DNSNames[0]
+	}
+	if len(cert.URIs) != 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.EmailAddresses) != 0 {
+		return cert.EmailAddresses[0]
+	}
+	return ""
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testCA signs certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cant generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cant create ca: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cant generate key: %v", err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("cant create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("cant marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// getTLSConn connects to the server with a client certificate
+func getTLSConn(t *testing.T, ca *testCA, certPEM, keyPEM []byte) *grpc.ClientConn {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("cant load client certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	conn, err := grpc.Dial(listenAddr, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   "127.0.0.1",
+	})))
+	if err != nil {
+		t.Fatalf("cant connect to grpc: %v", err)
+	}
+	return conn
+}
+
+func TestAuthConfig(t *testing.T) {
+	for idx, cfg := range []Config{
+		{TLSClientCAFile: "ca.pem"},
+		{TLSCertFile: "server.pem", TLSKeyFile: "server.key", TLSClientCAFile: "ca.pem", AuthKeys: map[string][]byte{"main": []byte("secret")}},
+	} {
+		ctx, finish := context.WithCancel(context.Background())
+		_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, cfg)
+		finish()
+		if err == nil {
+			wait(1)
+			t.Fatalf("[%d] expected a config error", idx)
+		}
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("cant create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	files := map[string][]byte{"ca.pem": ca.pem, "server.pem": serverCert, "server.key": serverKey}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatalf("cant write %s: %v", name, err)
+		}
+	}
+
+	for _, identity := range []string{"cn", "san"} {
+		ctx, finish := context.WithCancel(context.Background())
+		_, err = StartMyMicroserviceWithConfig(ctx, listenAddr, ACLData, Config{
+			TLSCertFile:     filepath.Join(dir, "server.pem"),
+			TLSKeyFile:      filepath.Join(dir, "server.key"),
+			TLSClientCAFile: filepath.Join(dir, "ca.pem"),
+			TLSIdentity:     identity,
+		})
+		if err != nil {
+			t.Fatalf("[%s] cant start server initial: %v", identity, err)
+		}
+		wait(1)
+		// with the san identity the common name must be ignored
+		commonName := func(consumer string) string {
+			if identity == "san" {
+				return "client"
+			}
+			return consumer
+		}
+
+		adminCert, adminKey := ca.issue(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: commonName("biz_admin")},
+			DNSNames:    []string{"biz_admin"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		conn := getTLSConn(t, ca, adminCert, adminKey)
+		biz := NewBizClient(conn)
+		if _, err := biz.Test(context.Background(), &Nothing{}); err != nil {
+			t.Fatalf("[%s] unexpected error: %v", identity, err)
+		}
+		conn.Close()
+
+		userCert, userKey := ca.issue(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: commonName("biz_user")},
+			DNSNames:    []string{"biz_user"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		conn = getTLSConn(t, ca, userCert, userKey)
+		biz = NewBizClient(conn)
+		// the consumer metadata does not override the certificate
+		if _, err := biz.Test(getConsumerCtx("biz_admin"), &Nothing{}); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("[%s] expected Unauthenticated for biz_user, got %v", identity, err)
+		}
+		conn.Close()
+
+		finish()
+		wait(1)
+	}
+}
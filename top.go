@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//defaultTopSize is how many consumers, methods and hosts are reported unless the request says otherwise
+const defaultTopSize = 10
+
+//kinds of names ranked in a top report
+const (
+	topConsumer = iota
+	topMethod
+	topHost
+)
+
+//topKey is a consumer, a method or a host
+type topKey struct {
+	kind int
+	name string
+}
+
+//topCounts are counts of calls of a consumer, a method or a host
+type topCounts struct {
+	calls   uint64
+	errors  uint64
+	latency float64 //total seconds
+}
+
+//countTop applies count to the counts of a consumer, a method and a host of a call.
+//An empty name is not ranked, it is a consumer of a call without one
+func countTop(top map[topKey]*topCounts, consumer string, method string, host string, count func(*topCounts)) {
+	for _, key := range []topKey{{topConsumer, consumer}, {topMethod, method}, {topHost, host}} {
+		if key.name == "" {
+			continue
+		}
+		counts, found := top[key]
+		if !found {
+			counts = &topCounts{}
+			top[key] = counts
+		}
+		count(counts)
+	}
+}
+
+//top ranks consumers, methods and hosts by the order over the calls made within the last window up to now
+func (s *statStore) top(window time.Duration, now time.Time, order TopOrder, n int) *TopReport {
+	seconds := int64((window + time.Second - 1) / time.Second)
+	last := now.Unix()
+	first := last - seconds + 1
+	total := make(map[topKey]*topCounts)
+	s.lock.Lock()
+	for _, bucket := range s.buckets {
+		if bucket.top == nil || bucket.second < first || bucket.second > last {
+			continue
+		}
+		for key, counts := range bucket.top {
+			sum, found := total[key]
+			if !found {
+				sum = &topCounts{}
+				total[key] = sum
+			}
+			sum.calls += counts.calls
+			sum.errors += counts.errors
+			sum.latency += counts.latency
+		}
+	}
+	s.lock.Unlock()
+
+	entries := make([][]*TopEntry, topHost+1)
+	for key, counts := range total {
+		entries[key.kind] = append(entries[key.kind], &TopEntry{
+			Name:    key.name,
+			Calls:   counts.calls,
+			Errors:  counts.errors,
+			Latency: counts.latency,
+		})
+	}
+	for kind := range entries {
+		entries[kind] = rankTop(entries[kind], order, n)
+	}
+	return &TopReport{
+		Timestamp: now.UnixNano(),
+		Consumers: entries[topConsumer],
+		Methods:   entries[topMethod],
+		Hosts:     entries[topHost],
+	}
+}
+
+//rankTop sorts entries by the order, the largest first and names in order on a tie, and keeps the first n
+func rankTop(entries []*TopEntry, order TopOrder, n int) []*TopEntry {
+	value := func(entry *TopEntry) float64 {
+		switch order {
+		case TopOrder_ERRORS:
+			return float64(entry.Errors)
+		case TopOrder_LATENCY:
+			return entry.Latency
+		default:
+			return float64(entry.Calls)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if value(entries[i]) != value(entries[j]) {
+			return value(entries[i]) > value(entries[j])
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+//GetTop is an implementation GetTop function of AdminServer interface.
+//It reports the heaviest consumers, methods and hosts over the recent window, the whole window kept if none is asked
+func (m *MsCtx) GetTop(ctx context.Context, req *TopRequest) (*TopReport, error) {
+	window, err := m.stats.windowOf(req.WindowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := TopOrder_name[int32(req.Order)]; !found {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown order %d", req.Order)
+	}
+	n := int(req.N)
+	if n == 0 {
+		n = defaultTopSize
+	}
+	return m.stats.top(window, time.Now(), req.Order, n), nil
+}
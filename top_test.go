@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func topNames(entries []*TopEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestStatStoreTop(t *testing.T) {
+	store := newStatStore(3*time.Second, Config{}.latencyBounds())
+	now := time.Unix(1000, 0)
+	store.addUsage("biz_old", "/main.Biz/Add", "10.0.0.2", now.Add(-time.Minute))
+	for i := 0; i < 3; i++ {
+		store.addUsage("biz_user", "/main.Biz/Check", "127.0.0.1", now)
+		store.addCode("biz_user", "/main.Biz/Check", "127.0.0.1", "OK", now)
+		store.addLatency("biz_user", "/main.Biz/Check", "127.0.0.1", time.Millisecond, now)
+	}
+	store.addUsage("biz_admin", "/main.Biz/Test", "10.0.0.1", now)
+	store.addCode("biz_admin", "/main.Biz/Test", "10.0.0.1", "Internal", now)
+	store.addLatency("biz_admin", "/main.Biz/Test", "10.0.0.1", time.Second, now)
+
+	for idx, tc := range []struct {
+		order    TopOrder
+		n        int
+		expected []string
+	}{
+		{TopOrder_CALLS, 10, []string{"biz_user", "biz_admin"}},
+		{TopOrder_ERRORS, 10, []string{"biz_admin", "biz_user"}},
+		{TopOrder_LATENCY, 10, []string{"biz_admin", "biz_user"}},
+		{TopOrder_CALLS, 1, []string{"biz_user"}},
+	} {
+		report := store.top(3*time.Second, now, tc.order, tc.n)
+		if have := topNames(report.Consumers); len(have) != len(tc.expected) || have[0] != tc.expected[0] {
+			t.Fatalf("[%d] expected %v, have %v", idx, tc.expected, have)
+		}
+	}
+
+	report := store.top(3*time.Second, now, TopOrder_CALLS, 10)
+	if len(report.Methods) != 2 || report.Methods[0].Name != "/main.Biz/Check" || report.Methods[0].Calls != 3 {
+		t.Fatalf("bad methods: %v", report.Methods)
+	}
+	if len(report.Hosts) != 2 || report.Hosts[1].Name != "10.0.0.1" || report.Hosts[1].Errors != 1 || report.Hosts[1].Latency != 1 {
+		t.Fatalf("bad hosts: %v", report.Hosts)
+	}
+}
+
+func TestGetTop(t *testing.T) {
+	acl := `{
+	"stat":      ["/main.Admin/GetTop"],
+	"biz_user":  ["/main.Biz/Check", "/main.Biz/Add"],
+	"biz_admin": ["/main.Biz/*"]
+}`
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, acl, Config{StatWindow: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Add(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Test(getConsumerCtx("biz_admin"), &Nothing{})
+
+	report, err := adm.GetTop(getConsumerCtx("stat"), &TopRequest{N: 1, Order: TopOrder_ERRORS})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// only the rejected call of biz_user to Test is an error
+	if len(report.Consumers) != 1 || report.Consumers[0].Name != "biz_user" || report.Consumers[0].Errors != 1 {
+		t.Fatalf("bad consumers: %v", report.Consumers)
+	}
+	if len(report.Methods) != 1 || report.Methods[0].Name != "/main.Biz/Test" || report.Methods[0].Calls != 2 {
+		t.Fatalf("bad methods: %v", report.Methods)
+	}
+	if len(report.Hosts) != 1 || report.Hosts[0].Name != "127.0.0.1" {
+		t.Fatalf("bad hosts: %v", report.Hosts)
+	}
+
+	for idx, req := range []*TopRequest{{WindowSeconds: 11}, {Order: TopOrder(42)}} {
+		if _, err := adm.GetTop(getConsumerCtx("stat"), req); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("[%d] expected InvalidArgument, got %v", idx, err)
+		}
+	}
+}
+
+func TestGetTopDefaultWindow(t *testing.T) {
+	acl := `{
+	"stat":     ["/main.Admin/GetTop"],
+	"biz_user": ["/main.Biz/Check"]
+}`
+	ctx, finish := context.WithCancel(context.Background())
+	_, err := StartMyMicroserviceWithConfig(ctx, listenAddr, acl, Config{})
+	if err != nil {
+		t.Fatalf("cant start server initial: %v", err)
+	}
+	wait(1)
+	defer func() {
+		finish()
+		wait(1)
+	}()
+
+	conn := getGrpcConn(t)
+	defer conn.Close()
+	biz := NewBizClient(conn)
+	adm := NewAdminClient(conn)
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+	biz.Check(getConsumerCtx("biz_user"), &Nothing{})
+
+	// a five minute window is kept without any config
+	report, err := adm.GetTop(getConsumerCtx("stat"), &TopRequest{WindowSeconds: 300})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Consumers) == 0 || report.Consumers[0].Name != "biz_user" || report.Consumers[0].Calls != 2 {
+		t.Fatalf("bad consumers: %v", report.Consumers)
+	}
+}